@@ -0,0 +1,236 @@
+/*
+运维接口，排查问题时查看和清理本节点的缓存，以及查看 key 在哈希环上属于哪个节点
+*/
+package geecache
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 运维接口的默认前缀，和节点之间通信的 /_geecache/ 分开，方便单独限制访问
+const defaultAdminBasePath = "/_geecache_admin/"
+
+// Ring 是运维接口查看哈希环需要的方法，HTTPPool 和 RPCPool 都实现了它
+type Ring interface {
+	// 返回自己的地址，以及所有节点和它们的权重
+	Peers() (self string, weights map[string]int)
+	// 返回 key 依次对应的所有节点，第一个是拥有者
+	Owners(key string) []string
+}
+
+var (
+	_ Ring = (*HTTPPool)(nil)
+	_ Ring = (*RPCPool)(nil)
+)
+
+// AdminOption 用来在 NewAdmin 时修改运维接口的配置
+type AdminOption func(*Admin)
+
+// 设置运维接口的前缀，默认是 /_geecache_admin/
+func WithAdminBasePath(basePath string) AdminOption {
+	return func(a *Admin) {
+		a.basePath = basePath
+	}
+}
+
+// 要求请求带上 Authorization: Bearer <token>，否则返回 401
+func WithAdminToken(token string) AdminOption {
+	return func(a *Admin) {
+		a.token = token
+	}
+}
+
+// Admin 是运维接口，所有操作都只针对本节点，删除 key 除外：
+//
+//	GET    <basePath>groups                     所有 Group 的内存上限、大小和条目数
+//	GET    <basePath>groups/<group>             一个 Group 的内存上限、大小和条目数
+//	DELETE <basePath>groups/<group>             清空本节点上这个 Group 的所有缓存
+//	GET    <basePath>groups/<group>/keys/<key>  key 是否缓存在本节点，以及它的大小和存在的时间
+//	DELETE <basePath>groups/<group>/keys/<key>  和 Group.Remove 一样从所有节点删除 key
+//	GET    <basePath>ring?key=<key>             所有节点的权重，以及 key 依次对应的节点
+type Admin struct {
+	basePath string
+	token    string
+	//查看哈希环，nil 表示没有注册节点
+	ring Ring
+}
+
+// ring 一般是 HTTPPool 或者 RPCPool，可以为 nil
+func NewAdmin(ring Ring, opts ...AdminOption) *Admin {
+	a := &Admin{
+		basePath: defaultAdminBasePath,
+		ring:     ring,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// 一个 Group 的大小
+type groupInfo struct {
+	Name       string     `json:"name"`
+	CacheBytes int64      `json:"cache_bytes"`
+	MainCache  CacheStats `json:"main_cache"`
+	HotCache   CacheStats `json:"hot_cache"`
+}
+
+func newGroupInfo(g *Group) groupInfo {
+	return groupInfo{
+		Name:       g.name,
+		CacheBytes: g.CacheBytes(),
+		MainCache:  g.CacheStats(MainCache),
+		HotCache:   g.CacheStats(HotCache),
+	}
+}
+
+// 一个 key 在本节点的缓存情况
+type keyInfo struct {
+	Key    string `json:"key"`
+	Cached bool   `json:"cached"`
+	// main 或者 hot
+	Cache string `json:"cache,omitempty"`
+	// 值的大小，以及压缩之后在缓存中占用的大小
+	Size       int        `json:"size,omitempty"`
+	StoredSize int        `json:"stored_size,omitempty"`
+	Age        string     `json:"age,omitempty"`
+	Expire     *time.Time `json:"expire,omitempty"`
+}
+
+// 哈希环的情况
+type ringInfo struct {
+	Self   string         `json:"self"`
+	Peers  map[string]int `json:"peers"`
+	Key    string         `json:"key,omitempty"`
+	Owners []string       `json:"owners,omitempty"`
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, a.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	if a.token != "" {
+		auth := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+a.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	path := r.URL.Path[len(a.basePath):]
+	switch {
+	case path == "ring":
+		a.serveRing(w, r)
+	case path == "groups":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		infos := []groupInfo{}
+		for _, name := range ListGroups() {
+			if g := GetGroup(name); g != nil {
+				infos = append(infos, newGroupInfo(g))
+			}
+		}
+		writeJSON(w, infos)
+	case strings.HasPrefix(path, "groups/"):
+		//groups/<group> 或者 groups/<group>/keys/<key>
+		parts := strings.SplitN(path[len("groups/"):], "/", 3)
+		g := GetGroup(parts[0])
+		if g == nil {
+			http.Error(w, "no such group: "+parts[0], http.StatusNotFound)
+			return
+		}
+		switch {
+		case len(parts) == 1:
+			a.serveGroup(w, r, g)
+		case len(parts) == 3 && parts[1] == "keys" && parts[2] != "":
+			a.serveKey(w, r, g, parts[2])
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *Admin) serveGroup(w http.ResponseWriter, r *http.Request, g *Group) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, newGroupInfo(g))
+	case http.MethodDelete:
+		g.mainCache.purge()
+		g.hotCache.purge()
+		g.negCache.purge()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *Admin) serveKey(w http.ResponseWriter, r *http.Request, g *Group, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		info := keyInfo{Key: key}
+		for _, c := range []struct {
+			name  string
+			cache *cache
+		}{{"main", &g.mainCache}, {"hot", &g.hotCache}} {
+			v, added, expire, ok := c.cache.peek(key)
+			if !ok {
+				continue
+			}
+			info.Cached, info.Cache, info.StoredSize = true, c.name, v.Len()
+			info.Size = v.Len()
+			if d, err := v.decompress(); err == nil {
+				info.Size = d.Len()
+			}
+			info.Age = time.Since(added).Round(time.Millisecond).String()
+			if !expire.IsZero() {
+				info.Expire = &expire
+			}
+			break
+		}
+		writeJSON(w, info)
+	case http.MethodDelete:
+		if err := g.Remove(key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *Admin) serveRing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.ring == nil {
+		http.Error(w, "no peers registered", http.StatusNotFound)
+		return
+	}
+	var info ringInfo
+	info.Self, info.Peers = a.ring.Peers()
+	if key := r.URL.Query().Get("key"); key != "" {
+		info.Key, info.Owners = key, a.ring.Owners(key)
+	}
+	writeJSON(w, info)
+}
+
+// 以 JSON 格式返回 v
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,93 @@
+package geecache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 发送带 token 的请求，解析 JSON 响应，返回状态码
+func adminDo(t *testing.T, method, u string, out interface{}) int {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil && res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestAdmin(t *testing.T) {
+	g := NewGroup("admin", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	pool := NewHTTPPool("http://self")
+	pool.Set("http://self", "http://peer1", "http://peer2")
+	srv := httptest.NewServer(NewAdmin(pool, WithAdminToken("token")))
+	defer srv.Close()
+	base := srv.URL + defaultAdminBasePath
+
+	if code := status(t, newRequest(t, base+"groups")); code != http.StatusUnauthorized {
+		t.Fatalf("request without token should get 401, got %d", code)
+	}
+
+	var groups []groupInfo
+	if code := adminDo(t, http.MethodGet, base+"groups", &groups); code != http.StatusOK {
+		t.Fatalf("list groups got %d", code)
+	}
+	found := false
+	for _, info := range groups {
+		found = found || info.Name == "admin"
+	}
+	if !found {
+		t.Fatalf("admin group not listed: %+v", groups)
+	}
+
+	var key keyInfo
+	if adminDo(t, http.MethodGet, base+"groups/admin/keys/Tom", &key); key.Cached {
+		t.Fatalf("Tom should not be cached yet")
+	}
+	g.Get("Tom")
+	if adminDo(t, http.MethodGet, base+"groups/admin/keys/Tom", &key); !key.Cached || key.Cache != "main" || key.Size != 3 || key.Age == "" {
+		t.Fatalf("key info of Tom = %+v", key)
+	}
+	var info groupInfo
+	if adminDo(t, http.MethodGet, base+"groups/admin", &info); info.MainCache.Items != 1 || info.CacheBytes != 2<<10 {
+		t.Fatalf("group info = %+v", info)
+	}
+
+	//删除 key 和清空 Group
+	if code := adminDo(t, http.MethodDelete, base+"groups/admin/keys/Tom", nil); code != http.StatusNoContent {
+		t.Fatalf("delete key got %d", code)
+	}
+	if _, ok := g.mainCache.get("Tom"); ok {
+		t.Fatalf("Tom should be removed")
+	}
+	g.Get("Jack")
+	if code := adminDo(t, http.MethodDelete, base+"groups/admin", nil); code != http.StatusNoContent {
+		t.Fatalf("purge group got %d", code)
+	}
+	if cs := g.CacheStats(MainCache); cs.Items != 0 {
+		t.Fatalf("purge should clear the group, got %d items", cs.Items)
+	}
+	if code := adminDo(t, http.MethodGet, base+"groups/missing", nil); code != http.StatusNotFound {
+		t.Fatalf("missing group got %d", code)
+	}
+
+	var ring ringInfo
+	adminDo(t, http.MethodGet, base+"ring?key=Tom", &ring)
+	if ring.Self != "http://self" || len(ring.Peers) != 3 || len(ring.Owners) != 3 || ring.Owners[0] != pool.peers.Get("Tom") {
+		t.Fatalf("ring info = %+v", ring)
+	}
+}
@@ -0,0 +1,281 @@
+package arc
+
+import (
+	"container/list"
+	"geecache/lru"
+	"time"
+)
+
+// Cache 是自适应替换缓存（Adaptive Replacement Cache），接口与 lru.Cache 相同。
+// t1 保存只访问过一次的条目，t2 保存访问过多次的条目，
+// b1、b2 分别记录最近从 t1、t2 淘汰的 key（只有 key 和大小，没有值），
+// 根据 b1、b2 的命中情况动态调整 t1 的目标大小 p，
+// 所以大量只访问一次的扫描请求只会冲掉 t1，不会影响 t2 中的热点数据。
+// 这里的大小都按字节计算
+type Cache struct {
+	//cache允许最大内存，0 表示不限制
+	maxBytes int64
+	//t1 的目标大小
+	p int64
+	//四个链表，队首是最近访问的
+	t1, t2, b1, b2 *segment
+	// 可选，当条目被清除时执行
+	OnEvicted func(key string, value lru.Value)
+}
+
+// 双向链表节点的数据类型
+type entry struct {
+	key   string
+	value lru.Value
+	// 过期时间，零值表示永不过期
+	expire time.Time
+	// 条目的大小，value 为 nil 时（在 b1、b2 中）仍然保留
+	size int64
+}
+
+// 判断条目在 now 时刻是否已经过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && !now.Before(e.expire)
+}
+
+// 带字典和大小统计的链表
+type segment struct {
+	ll     *list.List
+	cache  map[string]*list.Element
+	nbytes int64
+}
+
+func newSegment() *segment {
+	return &segment{ll: list.New(), cache: make(map[string]*list.Element)}
+}
+
+func (s *segment) pushFront(e *entry) {
+	s.cache[e.key] = s.ll.PushFront(e)
+	s.nbytes += e.size
+}
+
+func (s *segment) remove(ele *list.Element) *entry {
+	e := s.ll.Remove(ele).(*entry)
+	delete(s.cache, e.key)
+	s.nbytes -= e.size
+	return e
+}
+
+func (s *segment) removeOldest() *entry {
+	if ele := s.ll.Back(); ele != nil {
+		return s.remove(ele)
+	}
+	return nil
+}
+
+func New(maxBytes int64, onEvicted func(string, lru.Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		t1:        newSegment(),
+		t2:        newSegment(),
+		b1:        newSegment(),
+		b2:        newSegment(),
+		OnEvicted: onEvicted,
+	}
+}
+
+// 查找功能，命中后移动到 t2，已经过期的条目视为未命中，并顺便删除
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	for _, s := range []*segment{c.t1, c.t2} {
+		ele, ok := s.cache[key]
+		if !ok {
+			continue
+		}
+		kv := ele.Value.(*entry)
+		if kv.expired(time.Now()) {
+			c.evict(s, ele)
+			return nil, false
+		}
+		//第二次访问，移动到 t2 的队首
+		c.t2.pushFront(s.remove(ele))
+		return kv.value, true
+	}
+	return
+}
+
+// 在 t1、t2 中查找但不移动条目，已经过期的条目视为不存在，但不会删除
+func (c *Cache) Peek(key string) (value lru.Value, expire time.Time, ok bool) {
+	for _, s := range []*segment{c.t1, c.t2} {
+		if ele, ok := s.cache[key]; ok {
+			e := ele.Value.(*entry)
+			if !e.expired(time.Now()) {
+				return e.value, e.expire, true
+			}
+			return nil, time.Time{}, false
+		}
+	}
+	return
+}
+
+// 添加，永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// 添加，并指定过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	e := &entry{key: key, value: value, expire: expire, size: int64(len(key)) + int64(value.Len())}
+	//已经在缓存中，更新值并移动到 t2
+	for _, s := range []*segment{c.t1, c.t2} {
+		if ele, ok := s.cache[key]; ok {
+			s.remove(ele)
+			c.t2.pushFront(e)
+			c.replace(false)
+			return
+		}
+	}
+	//在 b1 中命中，说明 t1 太小，增大 p
+	if ele, ok := c.b1.cache[key]; ok {
+		delta := int64(1)
+		if c.b1.nbytes > 0 && c.b2.nbytes > c.b1.nbytes {
+			delta = c.b2.nbytes / c.b1.nbytes
+		}
+		c.p += delta * e.size
+		if c.maxBytes != 0 && c.p > c.maxBytes {
+			c.p = c.maxBytes
+		}
+		c.b1.remove(ele)
+		c.t2.pushFront(e)
+		c.replace(false)
+		return
+	}
+	//在 b2 中命中，说明 t2 太小，减小 p
+	if ele, ok := c.b2.cache[key]; ok {
+		delta := int64(1)
+		if c.b2.nbytes > 0 && c.b1.nbytes > c.b2.nbytes {
+			delta = c.b1.nbytes / c.b2.nbytes
+		}
+		c.p -= delta * e.size
+		if c.p < 0 {
+			c.p = 0
+		}
+		c.b2.remove(ele)
+		c.t2.pushFront(e)
+		c.replace(true)
+		return
+	}
+	//全新的 key，放入 t1
+	c.t1.pushFront(e)
+	c.replace(false)
+	//限制 b1、b2 的大小，避免记录的 key 无限增长
+	if c.maxBytes != 0 {
+		for c.b1.nbytes > c.maxBytes-c.p && c.b1.removeOldest() != nil {
+		}
+		for c.b2.nbytes > c.p && c.b2.removeOldest() != nil {
+		}
+	}
+}
+
+// 超过最大内存时，根据 p 决定从 t1 还是 t2 淘汰，淘汰的 key 记录到对应的 b1、b2 中
+// inB2 表示本次添加的 key 是在 b2 中命中的
+func (c *Cache) replace(inB2 bool) {
+	for c.maxBytes != 0 && c.t1.nbytes+c.t2.nbytes > c.maxBytes {
+		if c.t1.ll.Len() > 0 && (c.t1.nbytes > c.p || (c.t1.nbytes == c.p && inB2) || c.t2.ll.Len() == 0) {
+			c.ghost(c.t1, c.b1)
+		} else {
+			c.ghost(c.t2, c.b2)
+		}
+	}
+}
+
+// 把 from 中最久没有访问的条目淘汰，只把 key 记录到 ghost 中
+func (c *Cache) ghost(from, ghost *segment) {
+	e := from.removeOldest()
+	value := e.value
+	e.value = nil
+	ghost.pushFront(e)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, value)
+	}
+}
+
+// 淘汰最久没有访问的条目，优先淘汰 t1
+func (c *Cache) RemoveOldest() {
+	if c.t1.ll.Len() > 0 {
+		c.ghost(c.t1, c.b1)
+	} else if c.t2.ll.Len() > 0 {
+		c.ghost(c.t2, c.b2)
+	}
+}
+
+// 删除指定的条目，返回条目是否存在
+func (c *Cache) Remove(key string) bool {
+	for _, s := range []*segment{c.t1, c.t2} {
+		if ele, ok := s.cache[key]; ok {
+			c.evict(s, ele)
+			return true
+		}
+	}
+	return false
+}
+
+// 删除所有已经过期的条目，返回删除的个数
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for _, s := range []*segment{c.t1, c.t2} {
+		for ele := s.ll.Back(); ele != nil; {
+			prev := ele.Prev()
+			if ele.Value.(*entry).expired(now) {
+				c.evict(s, ele)
+				n++
+			}
+			ele = prev
+		}
+	}
+	return n
+}
+
+// 修改最大内存，变小时按 p 从 t1、t2 淘汰，并缩小 b1、b2，0 表示不限制
+func (c *Cache) SetMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+	if c.maxBytes == 0 {
+		return
+	}
+	if c.p > c.maxBytes {
+		c.p = c.maxBytes
+	}
+	c.replace(false)
+	for c.b1.nbytes > c.maxBytes-c.p && c.b1.removeOldest() != nil {
+	}
+	for c.b2.nbytes > c.p && c.b2.removeOldest() != nil {
+	}
+}
+
+// 从旧到新遍历所有未过期的条目，先 t1 后 t2，不改变访问顺序，fn 返回 false 时停止
+func (c *Cache) Range(fn func(key string, value lru.Value, expire time.Time) bool) {
+	now := time.Now()
+	for _, s := range []*segment{c.t1, c.t2} {
+		for ele := s.ll.Back(); ele != nil; ele = ele.Prev() {
+			e := ele.Value.(*entry)
+			if e.expired(now) {
+				continue
+			}
+			if !fn(e.key, e.value, e.expire) {
+				return
+			}
+		}
+	}
+}
+
+func (c *Cache) Len() int {
+	return c.t1.ll.Len() + c.t2.ll.Len()
+}
+
+// 返回当前已使用的内存，不包括 b1、b2 中只记录了 key 的条目
+func (c *Cache) Bytes() int64 {
+	return c.t1.nbytes + c.t2.nbytes
+}
+
+// 彻底删除条目，不记录到 b1、b2
+func (c *Cache) evict(s *segment, ele *list.Element) {
+	e := s.remove(ele)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
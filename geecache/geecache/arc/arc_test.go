@@ -0,0 +1,88 @@
+package arc
+
+import (
+	"geecache/lru"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	arc := New(int64(0), nil)
+	arc.Add("key1", String("1234"))
+	if v, ok := arc.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := arc.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+func TestOnEvicted(t *testing.T) {
+	keys := make([]string, 0)
+	arc := New(int64(8), func(key string, value lru.Value) {
+		keys = append(keys, key)
+	})
+	arc.Add("k1", String("v1"))
+	arc.Add("k2", String("v2"))
+	arc.Add("k3", String("v3"))
+	if len(keys) != 1 || keys[0] != "k1" || arc.Len() != 2 || arc.Bytes() != 8 {
+		t.Fatalf("oldest key k1 should be evicted, got %v", keys)
+	}
+	// k1 在 b1 中，再次添加时直接进入 t2
+	arc.Add("k1", String("v1"))
+	if _, ok := arc.t2.cache["k1"]; !ok {
+		t.Fatalf("ghost hit k1 should be added to t2")
+	}
+}
+
+// 扫描不应该冲掉多次访问的热点数据
+func TestScanResistance(t *testing.T) {
+	arc := New(int64(100), nil)
+	lru := lru.New(int64(100), nil)
+	hot := []string{"h1", "h2", "h3"}
+	for _, k := range hot {
+		for i := 0; i < 2; i++ {
+			arc.Add(k, String("hot"))
+			lru.Add(k, String("hot"))
+			arc.Get(k)
+			lru.Get(k)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		k := "scan" + strconv.Itoa(i)
+		arc.Add(k, String("v"))
+		lru.Add(k, String("v"))
+	}
+	for _, k := range hot {
+		if _, ok := arc.Get(k); !ok {
+			t.Fatalf("hot key %s should survive a scan in arc", k)
+		}
+		if _, ok := lru.Get(k); ok {
+			t.Fatalf("hot key %s should be flushed by a scan in lru", k)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	arc := New(int64(0), nil)
+	arc.AddWithExpire("k1", String("v1"), time.Now().Add(-time.Second))
+	arc.AddWithExpire("k2", String("v2"), time.Now().Add(-time.Second))
+	arc.Add("k3", String("v3"))
+	arc.Get("k3")
+	if _, ok := arc.Get("k1"); ok {
+		t.Fatalf("expired k1 should be a miss")
+	}
+	if n := arc.RemoveExpired(); n != 1 || arc.Len() != 1 || arc.Bytes() != 4 {
+		t.Fatalf("RemoveExpired removed %d keys, %d left", n, arc.Len())
+	}
+	if !arc.Remove("k3") || arc.Len() != 0 {
+		t.Fatalf("Remove k3 failed")
+	}
+}
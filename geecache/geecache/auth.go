@@ -0,0 +1,132 @@
+/*
+节点之间的请求签名，防止能访问到节点的任何人读取或者修改缓存
+*/
+package geecache
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//签名相关的请求头
+	timestampHeader = "X-Geecache-Timestamp"
+	nonceHeader     = "X-Geecache-Nonce"
+	signatureHeader = "X-Geecache-Signature"
+	//默认只接受 30 秒以内的请求
+	defaultReplayWindow = 30 * time.Second
+)
+
+// 开启请求签名，所有节点必须使用相同的 secret。
+// 签名是 HMAC-SHA256(secret, 方法、时间戳、随机数、group、key、请求体的哈希)，
+// 没有签名、签名错误、时间戳超出窗口或者随机数重复的请求返回 401。
+// 统计数据 /_geecache/_stats 不需要签名。secret 为空时 panic，空的密钥等于没有签名
+func WithSecret(secret []byte) HTTPPoolOption {
+	if len(secret) == 0 {
+		panic("empty secret")
+	}
+	return func(p *HTTPPool) {
+		p.secret = secret
+	}
+}
+
+// 设置时间戳与本机时间允许相差的范围，默认 30 秒，需要考虑节点之间的时钟误差。
+// 只在 WithSecret 开启签名时生效
+func WithReplayWindow(window time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.replayWindow = window
+	}
+}
+
+//根据 WithSecret 和 WithReplayWindow 的配置创建 signer，没有 secret 时返回 nil
+func newSigner(secret []byte, window time.Duration) *signer {
+	if len(secret) == 0 {
+		return nil
+	}
+	if window <= 0 {
+		window = defaultReplayWindow
+	}
+	return &signer{secret: secret, window: window}
+}
+
+//为请求签名，并校验收到的请求
+type signer struct {
+	secret []byte
+	window time.Duration
+
+	mu sync.Mutex
+	//窗口内已经使用过的随机数，以及它的过期时间
+	seen map[string]time.Time
+	//上一次清理 seen 的时间
+	lastPurge time.Time
+}
+
+//计算签名，各个字段之间用换行分隔，避免拼接之后产生歧义
+func (s *signer) mac(method, timestamp, nonce, group, key string, body []byte) string {
+	sum := sha256.Sum256(body)
+	h := hmac.New(sha256.New, s.secret)
+	for _, field := range []string{method, timestamp, nonce, group, key, hex.EncodeToString(sum[:])} {
+		h.Write([]byte(field))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//为发往其它节点的请求添加签名
+func (s *signer) sign(req *http.Request, group, key string, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	b := make([]byte, 16)
+	rand.Read(b)
+	nonce := hex.EncodeToString(b)
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(nonceHeader, nonce)
+	req.Header.Set(signatureHeader, s.mac(req.Method, timestamp, nonce, group, key, body))
+}
+
+//校验收到的请求
+func (s *signer) verify(r *http.Request, group, key string, body []byte) error {
+	timestamp, nonce, sig := r.Header.Get(timestampHeader), r.Header.Get(nonceHeader), r.Header.Get(signatureHeader)
+	if timestamp == "" || nonce == "" || sig == "" {
+		return errors.New("missing signature")
+	}
+	expect := s.mac(r.Method, timestamp, nonce, group, key, body)
+	if !hmac.Equal([]byte(sig), []byte(expect)) {
+		return errors.New("invalid signature")
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	now := time.Now()
+	if d := now.Sub(time.Unix(sec, 0)); d > s.window || d < -s.window {
+		return errors.New("timestamp outside replay window")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen == nil {
+		s.seen = make(map[string]time.Time)
+	}
+	//每隔一个窗口清理一次过期的随机数
+	if now.Sub(s.lastPurge) > s.window {
+		for n, expire := range s.seen {
+			if now.After(expire) {
+				delete(s.seen, n)
+			}
+		}
+		s.lastPurge = now
+	}
+	if _, ok := s.seen[nonce]; ok {
+		return errors.New("replayed request")
+	}
+	//时间戳在窗口内的请求都可能被重放，随机数至少要保留到时间戳离开窗口
+	s.seen[nonce] = time.Unix(sec, 0).Add(s.window)
+	return nil
+}
@@ -0,0 +1,146 @@
+package geecache
+
+import (
+	"context"
+	pb "geecache/geecachepb"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHTTPPoolSecret(t *testing.T) {
+	NewGroup("http_auth", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("", WithSecret([]byte("secret"))))
+	defer srv.Close()
+
+	pool := NewHTTPPool("http://self", WithSecret([]byte("secret")))
+	pool.Set(srv.URL)
+	getter := pool.getters[srv.URL]
+	out := &pb.Response{}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "http_auth", Key: "Tom"}, out); err != nil || string(out.Value) != "Tom" {
+		t.Fatalf("signed Get = %q, %v", out.Value, err)
+	}
+	if err := getter.Set(&pb.SetRequest{Group: "http_auth", Key: "Jack", Value: []byte("589")}); err != nil {
+		t.Fatalf("signed Set failed: %v", err)
+	}
+	batch := &pb.BatchResponse{}
+	if err := getter.GetMany(context.Background(), &pb.BatchRequest{Group: "http_auth", Keys: []string{"Sam"}}, batch); err != nil {
+		t.Fatalf("signed GetMany failed: %v", err)
+	}
+
+	// 没有签名，或者使用了错误的 secret
+	u := srv.URL + defaultBasePath + "http_auth/Tom"
+	if code := status(t, newRequest(t, u)); code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request should get 401, got %d", code)
+	}
+	wrong := &signer{secret: []byte("wrong"), window: defaultReplayWindow}
+	req := newRequest(t, u)
+	wrong.sign(req, "http_auth", "Tom", nil)
+	if code := status(t, req); code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret should get 401, got %d", code)
+	}
+
+	// 签名只对签名时的 key 有效
+	right := &signer{secret: []byte("secret"), window: defaultReplayWindow}
+	req = newRequest(t, srv.URL+defaultBasePath+"http_auth/Jack")
+	right.sign(req, "http_auth", "Tom", nil)
+	if code := status(t, req); code != http.StatusUnauthorized {
+		t.Fatalf("signature for another key should get 401, got %d", code)
+	}
+
+	// 同一个请求重放
+	req = newRequest(t, u)
+	right.sign(req, "http_auth", "Tom", nil)
+	replay := newRequest(t, u)
+	replay.Header = req.Header.Clone()
+	if code := status(t, req); code != http.StatusOK {
+		t.Fatalf("signed request should get 200, got %d", code)
+	}
+	if code := status(t, replay); code != http.StatusUnauthorized {
+		t.Fatalf("replayed request should get 401, got %d", code)
+	}
+
+	// 时间戳超出窗口
+	req = newRequest(t, u)
+	old := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	req.Header.Set(timestampHeader, old)
+	req.Header.Set(nonceHeader, "nonce")
+	req.Header.Set(signatureHeader, right.mac(http.MethodGet, old, "nonce", "http_auth", "Tom", nil))
+	if code := status(t, req); code != http.StatusUnauthorized {
+		t.Fatalf("stale request should get 401, got %d", code)
+	}
+}
+
+func TestHTTPPoolSecretEscapedKey(t *testing.T) {
+	NewGroup("http_auth_escaped", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("", WithSecret([]byte("secret"))))
+	defer srv.Close()
+	pool := NewHTTPPool("http://self", WithSecret([]byte("secret")))
+	pool.Set(srv.URL)
+	getter := pool.getters[srv.URL]
+
+	// 签名的 key 和服务端从路径中解析出的 key 要一致
+	for _, key := range []string{"Tom Jack", "a+b", "a/b", "百分%"} {
+		out := &pb.Response{}
+		if err := getter.Get(context.Background(), &pb.Request{Group: "http_auth_escaped", Key: key}, out); err != nil || string(out.Value) != key {
+			t.Fatalf("signed Get(%q) = %q, %v", key, out.Value, err)
+		}
+		if err := getter.Set(&pb.SetRequest{Group: "http_auth_escaped", Key: key, Value: []byte("v")}); err != nil {
+			t.Fatalf("signed Set(%q) failed: %v", key, err)
+		}
+		if err := getter.Remove(&pb.Request{Group: "http_auth_escaped", Key: key}); err != nil {
+			t.Fatalf("signed Remove(%q) failed: %v", key, err)
+		}
+	}
+}
+
+func newRequest(t *testing.T, u string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// 发送请求，返回状态码
+func status(t *testing.T, req *http.Request) int {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestHTTPPoolSecretOptions(t *testing.T) {
+	// 只设置窗口不会开启签名
+	if p := NewHTTPPool("http://self", WithReplayWindow(time.Minute)); p.signer != nil {
+		t.Fatal("WithReplayWindow alone should not enable signing")
+	}
+	// 窗口在 secret 之前设置也会生效
+	p := NewHTTPPool("http://self", WithReplayWindow(time.Minute), WithSecret([]byte("secret")))
+	if p.signer == nil || p.signer.window != time.Minute {
+		t.Fatalf("signer = %+v, want window %v", p.signer, time.Minute)
+	}
+	if p := NewHTTPPool("http://self", WithSecret([]byte("secret"))); p.signer.window != defaultReplayWindow {
+		t.Fatalf("default window = %v, want %v", p.signer.window, defaultReplayWindow)
+	}
+	for _, secret := range [][]byte{nil, {}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("WithSecret(%q) should panic", secret)
+				}
+			}()
+			WithSecret(secret)
+		}()
+	}
+}
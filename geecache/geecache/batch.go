@@ -0,0 +1,168 @@
+/*
+批量获取，同一个节点上的 key 只发送一次请求
+*/
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pb "geecache/geecachepb"
+	"log"
+	"sync"
+)
+
+// Result 是 GetMany 中一个 key 的结果
+type Result struct {
+	Key   string
+	Value ByteView
+	Err   error
+}
+
+// GetMany 批量获取多个 key，返回的结果与 keys 一一对应，
+// 每个 key 的错误单独返回，一个 key 失败不影响其它 key
+func (g *Group) GetMany(keys []string) []Result {
+	return g.GetManyContext(context.Background(), keys)
+}
+
+// GetManyContext 和 GetMany 相同，ctx 会传递给 ContextGetter 和远程节点。
+// 缓存未命中的 key 按拥有者分组，每个远程节点只发送一个批量请求，
+// 自己拥有的 key 并发地调用 Getter，批量请求失败时这些 key 跳过失败的节点逐个载入
+func (g *Group) GetManyContext(ctx context.Context, keys []string) []Result {
+	results := make([]Result, len(keys))
+	//同一个 key 只获取一次，记录它在 keys 中的所有位置
+	positions := make(map[string][]int, len(keys))
+	var misses []string
+	for i, key := range keys {
+		results[i].Key = key
+		if _, ok := positions[key]; ok {
+			positions[key] = append(positions[key], i)
+			continue
+		}
+		positions[key] = []int{i}
+		g.Stats.Gets.Add(1)
+		if key == "" {
+			continue
+		}
+		if v, err, ok := g.lookupCache(key); ok {
+			results[i].Value, results[i].Err = v, err
+			continue
+		}
+		misses = append(misses, key)
+	}
+
+	//按拥有者分组，自己拥有的 key 放在 local 中
+	var local []string
+	remote := make(map[PeerGetter][]string)
+	for _, key := range misses {
+		//其它节点转发来的请求只在本机载入
+		if g.peers != nil && !isForwarded(ctx) {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				remote[peer] = append(remote[peer], key)
+				continue
+			}
+		}
+		local = append(local, key)
+	}
+
+	var mu sync.Mutex
+	set := func(key string, value ByteView, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, i := range positions[key] {
+			results[i].Value, results[i].Err = value, err
+		}
+	}
+	var wg sync.WaitGroup
+	//failed 是批量请求失败的节点，逐个载入时不再请求它
+	loadAll := func(keys []string, failed PeerGetter) {
+		for _, key := range keys {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				value, err := g.load(ctx, key, failed)
+				set(key, value, err)
+			}(key)
+		}
+	}
+	loadAll(local, nil)
+	for peer, keys := range remote {
+		wg.Add(1)
+		go func(peer PeerGetter, keys []string) {
+			defer wg.Done()
+			res, err := g.getManyFromPeer(ctx, peer, keys)
+			if err != nil {
+				g.Stats.PeerErrors.Add(1)
+				log.Println("[GeeCache] Failed to get many from peer", err)
+				//批量请求失败，逐个载入，跳过这个节点，尝试副本或者本机
+				loadAll(keys, peer)
+				return
+			}
+			returned := make(map[string]bool, len(res))
+			for _, r := range res {
+				set(r.Key, r.Value, r.Err)
+				returned[r.Key] = true
+			}
+			for _, key := range keys {
+				if !returned[key] {
+					set(key, ByteView{}, fmt.Errorf("key %s missing in batch response", key))
+				}
+			}
+		}(peer, keys)
+	}
+	wg.Wait()
+	//相同 key 的其它位置在 set 时已经一起填好，这里补上直接命中缓存的
+	for _, idxs := range positions {
+		for _, i := range idxs[1:] {
+			results[i].Value, results[i].Err = results[idxs[0]].Value, results[idxs[0]].Err
+		}
+	}
+	return results
+}
+
+//向远程节点发送一个批量请求
+func (g *Group) getManyFromPeer(ctx context.Context, peer PeerGetter, keys []string) ([]Result, error) {
+	req := &pb.BatchRequest{
+		Group: g.name,
+		Keys:  keys,
+	}
+	res := &pb.BatchResponse{}
+	if err := peer.GetMany(ctx, req, res); err != nil {
+		return nil, err
+	}
+	//失败时这些 key 由 load 逐个载入，在那里计数
+	g.Stats.Loads.Add(int64(len(keys)))
+	results := make([]Result, 0, len(res.GetResults()))
+	for _, r := range res.GetResults() {
+		result := Result{Key: r.GetKey()}
+		switch {
+		case r.GetNotFound():
+			result.Err = ErrNotFound
+		case r.GetError() != "":
+			result.Err = errors.New(r.GetError())
+		default:
+			result.Value = ByteView{b: r.GetValue(), expire: unixNanoTime(r.GetExpire())}
+			g.Stats.PeerLoads.Add(1)
+			g.sampleHotCache(r.GetKey(), result.Value)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+//把 GetMany 的结果编码为 BatchResponse，供 HTTPPool 和 RPCPool 返回给其它节点
+func batchResponse(results []Result, out *pb.BatchResponse) {
+	for _, r := range results {
+		br := &pb.BatchResult{Key: r.Key}
+		switch {
+		case errors.Is(r.Err, ErrNotFound):
+			br.NotFound = true
+		case r.Err != nil:
+			br.Error = r.Err.Error()
+		default:
+			br.Value = r.Value.ByteSlice()
+			br.Expire = unixNano(r.Value.expire)
+		}
+		out.Results = append(out.Results, br)
+	}
+}
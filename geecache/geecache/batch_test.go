@@ -0,0 +1,85 @@
+package geecache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// 按 key 指定拥有者的假 PeerPicker，没有指定的 key 由自己拥有
+type mapPicker map[string]*fakePeer
+
+func (p mapPicker) PickPeer(key string) (PeerGetter, bool) {
+	if peer := p[key]; peer != nil {
+		return peer, true
+	}
+	return nil, false
+}
+
+func (p mapPicker) PickPeers(key string, n int) []PeerGetter {
+	// 远程的拥有者之后是自己
+	if peer := p[key]; peer != nil && n > 1 {
+		return []PeerGetter{peer, nil}
+	}
+	peer, _ := p.PickPeer(key)
+	return []PeerGetter{peer}
+}
+
+func (p mapPicker) GetAll() []PeerGetter {
+	return nil
+}
+
+func TestGetMany(t *testing.T) {
+	var mu sync.Mutex
+	loads := make(map[string]int)
+	gee := NewGroup("get_many", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			mu.Lock()
+			loads[key]++
+			mu.Unlock()
+			if key == "Unknown" {
+				return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+			}
+			return []byte(key), nil
+		}))
+	a, b := &fakePeer{name: "a"}, &fakePeer{name: "b", down: true}
+	gee.RegisterPeers(mapPicker{"a1": a, "a2": a, "b1": b})
+
+	keys := []string{"Tom", "a1", "a2", "Tom", "b1", "Unknown"}
+	results := gee.GetMany(keys)
+	expect := []string{"Tom", "a:a1", "a:a2", "Tom", "b1", ""}
+	for i, r := range results {
+		if r.Key != keys[i] {
+			t.Fatalf("result %d should be %s, got %s", i, keys[i], r.Key)
+		}
+		if keys[i] == "Unknown" {
+			if !errors.Is(r.Err, ErrNotFound) {
+				t.Fatalf("expect ErrNotFound for Unknown, got %v", r.Err)
+			}
+			continue
+		}
+		if r.Err != nil || r.Value.String() != expect[i] {
+			t.Fatalf("GetMany(%s) = %q, %v, expect %q", keys[i], r.Value.String(), r.Err, expect[i])
+		}
+	}
+	// 同一个节点上的 key 只发送一个请求，重复的 key 只载入一次
+	if a.batches != 1 || a.gets != 0 {
+		t.Fatalf("expect 1 batch to a, got %d batches and %d gets", a.batches, a.gets)
+	}
+	if loads["Tom"] != 1 {
+		t.Fatalf("duplicate keys should be loaded once, got %d", loads["Tom"])
+	}
+	// b 不可用时逐个载入，最后从本机获取
+	if b.batches != 1 || loads["b1"] != 1 {
+		t.Fatalf("b1 should fall back to local load, batches = %d, loads = %d", b.batches, loads["b1"])
+	}
+	// 逐个载入时不会再请求失败的节点
+	if b.gets != 0 {
+		t.Fatalf("failed peer b should not be asked again, got %d gets", b.gets)
+	}
+	// 每个未命中的 key 只计一次载入：Tom、a1、a2、b1、Unknown
+	if n := gee.Stats.Loads.Get(); n != 5 {
+		t.Fatalf("expect 5 loads, got %d", n)
+	}
+}
@@ -1,9 +1,15 @@
 package geecache
 
+import "time"
+
 // ByteView 是一个只读的 byte 类型的视图，用来表现缓存值
 
 type ByteView struct {
 	b []byte
+	//b 的压缩算法，nil 表示没有压缩，只有缓存内部保存的值会压缩
+	codec Codec
+	//过期时间，零值表示永不过期，返回给其它节点时一起传递
+	expire time.Time
 }
 
 // lru.Value 接口的实现，返回所占用的内存大小
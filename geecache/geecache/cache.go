@@ -1,36 +1,300 @@
 package geecache
 
 import (
+	"geecache/arc"
+	"geecache/lfu"
 	"geecache/lru"
+	"geecache/tinylfu"
 	"sync"
+	"time"
 )
 
+// 后台清理过期条目的默认间隔
+const defaultJanitorInterval = time.Minute
+
+// Policy 是淘汰策略需要实现的接口，lru、lfu、arc、tinylfu 包中的 Cache 都实现了它，
+// 条目被淘汰时调用创建时传入的 OnEvicted 回调
+type Policy interface {
+	Add(key string, value lru.Value)
+	AddWithExpire(key string, value lru.Value, expire time.Time)
+	Get(key string) (value lru.Value, ok bool)
+	// 查找但不影响淘汰的顺序
+	Peek(key string) (value lru.Value, expire time.Time, ok bool)
+	Remove(key string) bool
+	RemoveExpired() int
+	Len() int
+	Bytes() int64
+	// 修改最大内存，变小时立即淘汰多出来的条目
+	SetMaxBytes(maxBytes int64)
+	// 从旧到新遍历所有未过期的条目，不改变访问顺序
+	Range(fn func(key string, value lru.Value, expire time.Time) bool)
+}
+
+var (
+	_ Policy = (*lru.Cache)(nil)
+	_ Policy = (*lfu.Cache)(nil)
+	_ Policy = (*arc.Cache)(nil)
+	_ Policy = (*tinylfu.Cache)(nil)
+)
+
+// EvictionPolicy 用来选择 cache 使用的淘汰策略
+type EvictionPolicy int
+
+const (
+	// 淘汰最久没有访问的条目
+	LRU EvictionPolicy = iota
+	// 淘汰访问次数最少的条目
+	LFU
+	// 自适应替换，兼顾访问时间和访问次数，不怕扫描
+	ARC
+	// LRU 前面加一个按访问频率的准入过滤，只访问一次的条目不会挤掉热点数据
+	TinyLFU
+)
+
+// 根据淘汰策略创建对应的实现
+func newPolicy(p EvictionPolicy, maxBytes int64, onEvicted func(string, lru.Value)) Policy {
+	switch p {
+	case LFU:
+		return lfu.New(maxBytes, onEvicted)
+	case ARC:
+		return arc.New(maxBytes, onEvicted)
+	case TinyLFU:
+		return tinylfu.New(maxBytes, onEvicted)
+	default:
+		return lru.New(maxBytes, onEvicted)
+	}
+}
+
+// 默认的分片数，1 表示不分片
+const defaultShards = 1
+
+// cache 由若干个互不相关的分片组成，key 按哈希值落到其中一个分片，
+// 每个分片有自己的锁和淘汰策略，内存上限平分给各个分片，
+// 这样并发访问不同的 key 时不会争抢同一把锁
 type cache struct {
-	mu         sync.Mutex
-	lru        *lru.Cache
 	cacheBytes int64
+	// 淘汰策略，默认是 LRU
+	evictionPolicy EvictionPolicy
+	// 后台清理过期条目的间隔，0 表示使用默认值
+	janitorInterval time.Duration
+	// 分片数，0 表示使用默认值
+	nshards int
+
+	//延迟初始化，用到的时候再初始化
+	initOnce sync.Once
+	shards   []*shard
+	// 第一次出现会过期的条目时，才启动后台清理
+	janitorOnce sync.Once
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// 一个分片，相当于一个独立的带锁的缓存
+type shard struct {
+	mu     sync.Mutex
+	policy Policy
+	// 这个分片的内存上限，由 mu 保护
+	maxBytes int64
+	// 统计数据，由 mu 保护，nevict 只统计内存不足时的淘汰
+	nget, nhit, nevict int64
+	// 正在添加条目或者缩小内存上限，只有这时触发的 OnEvicted 是因为内存不足，
+	// Remove、过期等触发的不计入 nevict，由 mu 保护
+	evicting bool
+}
+
+func (c *cache) init() {
+	c.initOnce.Do(func() {
+		n := c.nshards
+		if n <= 0 {
+			n = defaultShards
+		}
+		c.shards = make([]*shard, n)
+		for i := range c.shards {
+			s := &shard{maxBytes: fraction(c.cacheBytes, int64(n))}
+			s.policy = c.newPolicy(s)
+			c.shards[i] = s
+		}
+		c.stop = make(chan struct{})
+	})
+}
+
+// 按比例分出的内存上限，即 cacheBytes 的 1/n，用于分片以及 Group 的热点缓存和负缓存，
+// cacheBytes 较小时至少为 1，因为 0 表示没有上限
+func fraction(cacheBytes, n int64) int64 {
+	b := cacheBytes / n
+	if b == 0 && cacheBytes > 0 {
+		b = 1
+	}
+	return b
+}
+
+// 为分片创建淘汰策略，因为内存不足淘汰时计入分片的统计数据
+func (c *cache) newPolicy(s *shard) Policy {
+	return newPolicy(c.evictionPolicy, s.maxBytes, func(string, lru.Value) {
+		if s.evicting {
+			s.nevict++
+		}
+	})
+}
+
+// 根据 key 的 FNV-1a 哈希选择分片
+func (c *cache) shard(key string) *shard {
+	c.init()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+// 保存在淘汰策略中的值，记录添加的时间，Len 使用 ByteView 的大小
+type cacheValue struct {
+	ByteView
+	added time.Time
 }
 
-func (c *cache) add(key string, value ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	//延迟初始化，用到lru的时候再初始化
-	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
+func (c *cache) add(key string, value ByteView, expire time.Time) {
+	s := c.shard(key)
+	if !expire.IsZero() {
+		c.janitorOnce.Do(func() {
+			go c.janitor(c.stop)
+		})
 	}
-	//添加到lru中
-	c.lru.Add(key, value)
+	//读取时一起返回过期时间
+	value.expire = expire
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	//添加到淘汰策略中
+	s.evicting = true
+	s.policy.AddWithExpire(key, cacheValue{value, time.Now()}, expire)
+	s.evicting = false
 }
 
 func (c *cache) get(key string) (value ByteView, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nget++
+	//从淘汰策略中获取，过期的条目视为未命中
+	if v, ok := s.policy.Get(key); ok {
+		s.nhit++
+		return v.(cacheValue).ByteView, ok
+	}
+	return
+}
+
+// 查找但不影响淘汰的顺序和统计数据，同时返回添加时间和过期时间
+func (c *cache) peek(key string) (value ByteView, added, expire time.Time, ok bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, expire, ok := s.policy.Peek(key)
+	if !ok {
 		return
 	}
-	//从lru中获取
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+	cv := v.(cacheValue)
+	return cv.ByteView, cv.added, expire, true
+}
+
+func (c *cache) remove(key string) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy.Remove(key)
+}
+
+// 修改内存上限，平分给各个分片，变小时立即淘汰多出来的条目
+func (c *cache) resize(cacheBytes int64) {
+	c.init()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.maxBytes = fraction(cacheBytes, int64(len(c.shards)))
+		s.evicting = true
+		s.policy.SetMaxBytes(s.maxBytes)
+		s.evicting = false
+		s.mu.Unlock()
+	}
+}
+
+// 清空所有条目，直接换成新的淘汰策略，不计入淘汰次数
+func (c *cache) purge() {
+	c.init()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.policy = c.newPolicy(s)
+		s.mu.Unlock()
+	}
+}
+
+// 清空所有条目并停止后台清理，之后仍然可以使用，但不会再清理过期的条目
+func (c *cache) close() {
+	c.purge()
+	c.janitorOnce.Do(func() {})
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// 一个缓存条目，Snapshot 时使用
+type cacheEntry struct {
+	key    string
+	value  ByteView
+	expire time.Time
+}
+
+// 按分片依次取出所有未过期的条目，每个分片内从旧到新
+func (c *cache) entries() []cacheEntry {
+	c.init()
+	var es []cacheEntry
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.policy.Range(func(key string, value lru.Value, expire time.Time) bool {
+			es = append(es, cacheEntry{key, value.(cacheValue).ByteView, expire})
+			return true
+		})
+		s.mu.Unlock()
+	}
+	return es
+}
+
+// 汇总所有分片的统计数据
+func (c *cache) stats() CacheStats {
+	c.init()
+	var cs CacheStats
+	for _, s := range c.shards {
+		s.mu.Lock()
+		cs.Gets += s.nget
+		cs.Hits += s.nhit
+		cs.Evictions += s.nevict
+		cs.Bytes += s.policy.Bytes()
+		cs.Items += int64(s.policy.Len())
+		s.mu.Unlock()
+	}
+	return cs
+}
+
+// 定期清理过期的条目，否则没人访问的过期条目会一直占用 nbytes
+func (c *cache) janitor(stop chan struct{}) {
+	interval := c.janitorInterval
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, s := range c.shards {
+				s.mu.Lock()
+				s.policy.RemoveExpired()
+				s.mu.Unlock()
+			}
+		case <-stop:
+			return
+		}
 	}
-	return
 }
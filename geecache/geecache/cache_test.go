@@ -0,0 +1,99 @@
+package geecache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	c := &cache{cacheBytes: 16 << 10, nshards: 16}
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		c.add(k, ByteView{b: []byte(k)}, time.Time{})
+	}
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		if v, ok := c.get(k); !ok || v.String() != k {
+			t.Fatalf("cache hit %s failed", k)
+		}
+	}
+	if s := c.stats(); s.Items != 100 || s.Hits != 100 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	// 每个分片都应该分到一部分 key
+	for i, s := range c.shards {
+		if s.policy.Len() == 0 {
+			t.Fatalf("shard %d is empty", i)
+		}
+	}
+}
+
+func TestShardsSmallCacheBytes(t *testing.T) {
+	// cacheBytes 小于分片个数时每个分片仍然有上限
+	c := &cache{cacheBytes: 10, nshards: 16}
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i)
+		c.add(k, ByteView{b: []byte(k)}, time.Time{})
+	}
+	if s := c.stats(); s.Bytes > 16 {
+		t.Fatalf("cache with 10 bytes grew to %d bytes", s.Bytes)
+	}
+	c.resize(1 << 10)
+	c.resize(10)
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i)
+		c.add(k, ByteView{b: []byte(k)}, time.Time{})
+	}
+	if s := c.stats(); s.Bytes > 16 {
+		t.Fatalf("cache resized to 10 bytes grew to %d bytes", s.Bytes)
+	}
+}
+
+func TestEvictionsOnlyWhenFull(t *testing.T) {
+	c := &cache{cacheBytes: 1 << 10}
+	c.add("k1", ByteView{b: []byte("v1")}, time.Time{})
+	c.add("k2", ByteView{b: []byte("v2")}, time.Now().Add(-time.Second))
+	// 删除和过期不算淘汰
+	c.remove("k1")
+	c.get("k2")
+	if s := c.stats(); s.Evictions != 0 || s.Items != 0 {
+		t.Fatalf("remove and expiry should not count as evictions, got %+v", s)
+	}
+	// 内存不足时才算
+	c.resize(8)
+	for i := 0; i < 10; i++ {
+		k := strconv.Itoa(i)
+		c.add(k, ByteView{b: []byte("v")}, time.Time{})
+	}
+	if s := c.stats(); s.Evictions == 0 {
+		t.Fatalf("adding to a full cache should count evictions, got %+v", s)
+	}
+}
+
+// 并发读取时的吞吐量，用 -cpu 1,2,4,8 对比分片前后随 GOMAXPROCS 的变化：
+// go test -run NONE -bench CacheGet -cpu 1,2,4,8
+func benchmarkCacheGet(b *testing.B, shards int) {
+	const keys = 1 << 12
+	c := &cache{cacheBytes: 64 << 20, nshards: shards}
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "key" + strconv.Itoa(i)
+		c.add(names[i], ByteView{b: []byte("value")}, time.Time{})
+	}
+	var seed uint32
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		//每个协程从不同的位置开始，避免同时访问同一个 key
+		i := int(atomic.AddUint32(&seed, 1)) * 997
+		for pb.Next() {
+			c.get(names[i&(keys-1)])
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheGet1Shard(b *testing.B)   { benchmarkCacheGet(b, 1) }
+func BenchmarkCacheGet16Shards(b *testing.B) { benchmarkCacheGet(b, 16) }
+func BenchmarkCacheGet64Shards(b *testing.B) { benchmarkCacheGet(b, 64) }
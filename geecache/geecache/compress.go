@@ -0,0 +1,186 @@
+/*
+压缩，大的值在缓存中压缩保存，节点之间通过 Content-Encoding 协商是否压缩传输
+*/
+package geecache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codec 是压缩算法需要实现的接口
+type Codec interface {
+	// 算法的名称，用作 HTTP 的 Content-Encoding，例如 gzip
+	Name() string
+	Encode(b []byte) ([]byte, error)
+	Decode(b []byte) ([]byte, error)
+}
+
+// Gzip 使用 compress/gzip 的默认压缩级别
+var Gzip Codec = gzipCodec{}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (gzipCodec) Encode(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+var (
+	codecsMu sync.RWMutex
+	//所有可以解码的算法，名称 -> Codec
+	codecs = map[string]Codec{"gzip": Gzip}
+)
+
+// RegisterCodec 注册一个压缩算法，之后收到这个 Content-Encoding 的请求和响应都可以解码，
+// WithCompression 会自动注册它使用的算法
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+func lookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// 所有已注册算法的名称，用作请求的 Accept-Encoding
+func acceptEncoding() string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// 开启压缩：不小于 threshold 字节的值用 codec 压缩之后再放入缓存，内存按压缩后的大小计算，
+// 读取时再解压。返回给其它节点的响应也会压缩，前提是对方的 Accept-Encoding 包含 codec 的名称，
+// 所以同一个 Group 在所有节点上应该使用相同的配置
+func WithCompression(codec Codec, threshold int) GroupOption {
+	RegisterCodec(codec)
+	return func(g *Group) {
+		g.codec = codec
+		g.compressThreshold = threshold
+	}
+}
+
+// 需要压缩时返回压缩后的 ByteView，压缩失败或者压缩之后没有变小时保持原样
+func (g *Group) compress(v ByteView) ByteView {
+	if g.codec == nil || v.Len() < g.compressThreshold {
+		return v
+	}
+	b, err := g.codec.Encode(v.b)
+	if err != nil {
+		log.Println("[GeeCache] Failed to compress", err)
+		return v
+	}
+	if len(b) >= v.Len() {
+		return v
+	}
+	return ByteView{b: b, codec: g.codec, expire: v.expire}
+}
+
+// 返回解压之后的 ByteView，没有压缩时原样返回
+func (v ByteView) decompress() (ByteView, error) {
+	if v.codec == nil {
+		return v, nil
+	}
+	b, err := v.codec.Decode(v.b)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return ByteView{b: b, expire: v.expire}, nil
+}
+
+// 请求方的 Accept-Encoding 是否包含 name
+func acceptsEncoding(r *http.Request, name string) bool {
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, token := range strings.Split(v, ",") {
+			//忽略 q 参数，例如 gzip;q=0.8
+			if i := strings.IndexByte(token, ';'); i >= 0 {
+				token = token[:i]
+			}
+			if strings.TrimSpace(token) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 写入响应体，请求方支持 group 的压缩算法并且响应体不小于阈值时压缩
+func writeBody(w http.ResponseWriter, r *http.Request, group *Group, body []byte) {
+	if c := group.codec; c != nil && len(body) >= group.compressThreshold && acceptsEncoding(r, c.Name()) {
+		if b, err := c.Encode(body); err == nil {
+			body = b
+			w.Header().Set("Content-Encoding", c.Name())
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// 读取请求体或者响应体，按 Content-Encoding 解压
+func readBody(h http.Header, r io.Reader) ([]byte, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	name := h.Get("Content-Encoding")
+	if name == "" || name == "identity" {
+		return body, nil
+	}
+	c, ok := lookupCodec(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", name)
+	}
+	return c.Decode(body)
+}
+
+// 压缩发往其它节点的请求体，本地同名的 Group 开启了压缩并且请求体不小于阈值时才压缩，
+// 返回压缩后的请求体和 Content-Encoding，没有压缩时 encoding 为空
+func compressRequest(group string, body []byte) (b []byte, encoding string) {
+	g := GetGroup(group)
+	if g == nil || g.codec == nil || len(body) < g.compressThreshold {
+		return body, ""
+	}
+	b, err := g.codec.Encode(body)
+	if err != nil {
+		return body, ""
+	}
+	return b, g.codec.Name()
+}
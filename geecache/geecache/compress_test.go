@@ -0,0 +1,103 @@
+package geecache
+
+import (
+	"context"
+	pb "geecache/geecachepb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 一个很容易压缩的 JSON
+var blob = "[" + strings.Repeat(`{"name":"Tom","score":630},`, 100) + `{"name":"Jack","score":589}]`
+
+func TestCompression(t *testing.T) {
+	g := NewGroup("compress", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "small" {
+				return []byte("630"), nil
+			}
+			return []byte(blob), nil
+		}), WithCompression(Gzip, 100))
+
+	if v, err := g.Get("blob"); err != nil || v.String() != blob {
+		t.Fatalf("Get compressed value failed: %v", err)
+	}
+	//缓存中保存的是压缩后的值，内存按压缩后的大小计算
+	if v, ok := g.mainCache.get("blob"); !ok || v.codec == nil || v.Len() >= len(blob) {
+		t.Fatalf("blob should be stored compressed, got %d bytes", v.Len())
+	}
+	if cs := g.CacheStats(MainCache); cs.Bytes >= int64(len(blob)) {
+		t.Fatalf("cache bytes should use the compressed size, got %d", cs.Bytes)
+	}
+	if v, err := g.Get("blob"); err != nil || v.String() != blob {
+		t.Fatalf("cache hit of compressed value failed: %v", err)
+	}
+
+	//小于阈值的值不压缩
+	if v, err := g.Get("small"); err != nil || v.String() != "630" {
+		t.Fatalf("Get small value = %q, %v", v, err)
+	}
+	if v, _ := g.mainCache.get("small"); v.codec != nil {
+		t.Fatalf("value below threshold should not be compressed")
+	}
+}
+
+// 记录响应的 Content-Encoding
+type encodingTransport struct {
+	encoding string
+}
+
+func (e *encodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		e.encoding = res.Header.Get("Content-Encoding")
+	}
+	return res, err
+}
+
+func TestHTTPCompression(t *testing.T) {
+	g := NewGroup("http_compress", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(blob), nil
+		}), WithCompression(Gzip, 100))
+	srv := httptest.NewServer(NewHTTPPool(""))
+	defer srv.Close()
+
+	rt := &encodingTransport{}
+	pool := NewHTTPPool("http://self", WithTransport(rt))
+	pool.Set(srv.URL)
+	getter := pool.getters[srv.URL]
+	out := &pb.Response{}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "http_compress", Key: "Tom"}, out); err != nil || string(out.Value) != blob {
+		t.Fatalf("Get compressed response failed: %v", err)
+	}
+	if rt.encoding != "gzip" {
+		t.Fatalf("response should be gzip encoded, got %q", rt.encoding)
+	}
+	batch := &pb.BatchResponse{}
+	if err := getter.GetMany(context.Background(), &pb.BatchRequest{Group: "http_compress", Keys: []string{"Tom", "Jack"}}, batch); err != nil {
+		t.Fatalf("GetMany compressed response failed: %v", err)
+	}
+	if len(batch.Results) != 2 || string(batch.Results[1].Value) != blob || rt.encoding != "gzip" {
+		t.Fatalf("GetMany response not decoded, encoding %q", rt.encoding)
+	}
+
+	//请求方没有 Accept-Encoding 时不压缩
+	req := newRequest(t, srv.URL+defaultBasePath+"http_compress/Tom")
+	req.Header.Set("Accept-Encoding", "identity")
+	if res, err := http.DefaultTransport.RoundTrip(req); err != nil || res.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("response should not be compressed without Accept-Encoding")
+	} else {
+		res.Body.Close()
+	}
+
+	//压缩之后的请求体
+	if err := getter.Set(&pb.SetRequest{Group: "http_compress", Key: "Sam", Value: []byte(blob + " ")}); err != nil {
+		t.Fatalf("Set compressed body failed: %v", err)
+	}
+	if v, err, ok := g.lookupCache("Sam"); !ok || err != nil || v.String() != blob+" " {
+		t.Fatalf("compressed Set not stored")
+	}
+}
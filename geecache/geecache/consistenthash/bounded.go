@@ -0,0 +1,144 @@
+package consistenthash
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Bounded 是有界负载的一致性哈希（Mirrokni 等人提出）：
+// 在哈希环上顺时针查找，跳过负载已经达到上限的节点，
+// 每个节点的上限为 (1+epsilon) 倍的平均负载（按权重分配），所以热点 key 不会压垮一个节点。
+// 负载由调用方通过 Inc、Done 维护，例如正在处理的请求数，
+// 负载不同时同一个 key 可能会被分到不同的节点
+type Bounded struct {
+	mu sync.Mutex
+	//哈希环，负载都没有达到上限时和 Map 的结果相同
+	ring *Map
+	//允许超出平均负载的比例
+	epsilon float64
+	//每个真实节点的当前负载
+	loads map[string]int
+	//所有节点的负载之和
+	total int
+	//所有节点的权重之和
+	totalWeight int
+}
+
+// replicas、fn 的含义与 New 相同，epsilon 必须大于0
+func NewBounded(replicas int, fn Hash, epsilon float64) *Bounded {
+	return &Bounded{
+		ring:    New(replicas, fn),
+		epsilon: epsilon,
+		loads:   make(map[string]int),
+	}
+}
+
+func (b *Bounded) Add(keys ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ring.Add(keys...)
+	b.sumWeights()
+}
+
+func (b *Bounded) AddWithWeight(key string, weight int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ring.AddWithWeight(key, weight)
+	b.sumWeights()
+}
+
+// 删除节点，同时丢弃它的负载
+func (b *Bounded) Remove(keys ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ring.Remove(keys...)
+	for _, key := range keys {
+		b.total -= b.loads[key]
+		delete(b.loads, key)
+	}
+	b.sumWeights()
+}
+
+// 节点变化之后重新计算权重之和
+func (b *Bounded) sumWeights() {
+	b.totalWeight = 0
+	for _, w := range b.ring.weights {
+		b.totalWeight += w
+	}
+}
+
+// 从 key 在环上的位置开始，返回第一个再增加一个负载也不会超过上限的节点
+func (b *Bounded) Get(key string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m := b.ring
+	if len(m.keys) == 0 {
+		return ""
+	}
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+	for i := 0; i < len(m.keys); i++ {
+		node := m.hashmap[m.keys[(idx+i)%len(m.keys)]]
+		if b.loads[node]+1 <= b.capacity(node) {
+			return node
+		}
+	}
+	//上限向上取整，不会所有节点都满，这里只是兜底
+	return m.hashmap[m.keys[idx%len(m.keys)]]
+}
+
+// 第一个节点与 Get 相同，副本按环上的顺序依次选取，不考虑负载
+func (b *Bounded) GetN(key string, n int) []string {
+	first := b.Get(key)
+	if first == "" || n <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m := b.ring
+	if n > len(m.weights) {
+		n = len(m.weights)
+	}
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+	return m.walk(idx, n, []string{first})
+}
+
+// 节点的负载上限，包括即将分配的这一个
+func (b *Bounded) capacity(node string) int {
+	avg := float64(b.total+1) * float64(b.ring.weights[node]) / float64(b.totalWeight)
+	return int(math.Ceil(avg * (1 + b.epsilon)))
+}
+
+// 节点的负载加一，例如开始处理一个请求
+func (b *Bounded) Inc(node string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.ring.weights[node]; !ok {
+		return
+	}
+	b.loads[node]++
+	b.total++
+}
+
+// 节点的负载减一，和 Inc 成对调用
+func (b *Bounded) Done(node string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.loads[node] > 0 {
+		b.loads[node]--
+		b.total--
+	}
+}
+
+// 返回节点的当前负载
+func (b *Bounded) Load(node string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.loads[node]
+}
@@ -18,6 +18,8 @@ type Map struct {
 	keys []int
 	//虚拟节点与真实节点的映射关系
 	hashmap map[int]string
+	//真实节点的权重，虚拟节点的个数为 replicas*权重
+	weights map[string]int
 }
 
 //创建一个Map
@@ -26,6 +28,7 @@ func New(relicas int, fn Hash) *Map {
 		hash:     fn,
 		replicas: relicas,
 		hashmap:  make(map[int]string),
+		weights:  make(map[string]int),
 	}
 	if m.hash == nil {
 		//默认实现，返回uint32
@@ -34,17 +37,64 @@ func New(relicas int, fn Hash) *Map {
 	return m
 }
 
-//允许传入0个或多个真实节点的名称
+//允许传入0个或多个真实节点的名称，权重都为1
 func (m *Map) Add(keys ...string) {
 	for _, key := range keys {
-		for i := 0; i < m.replicas; i++ {
-			//0key 1key 2key 3key ...
+		m.addVirtual(key, 1)
+	}
+	sort.Ints(m.keys)
+}
+
+//添加一个带权重的真实节点，分到的 key 的数量大致和权重成正比，
+//权重小于1时按1处理
+func (m *Map) AddWithWeight(key string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	m.addVirtual(key, weight)
+	sort.Ints(m.keys)
+}
+
+//为真实节点添加 replicas*weight 个虚拟节点，调用方负责排序
+func (m *Map) addVirtual(key string, weight int) {
+	m.weights[key] = weight
+	for i := 0; i < m.replicas*weight; i++ {
+		//0key 1key 2key 3key ...
+		hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		m.keys = append(m.keys, hash)
+		m.hashmap[hash] = key
+	}
+}
+
+//删除真实节点以及它所有的虚拟节点，其它节点的虚拟节点保持不变
+func (m *Map) Remove(keys ...string) {
+	removed := false
+	for _, key := range keys {
+		weight, ok := m.weights[key]
+		if !ok {
+			continue
+		}
+		delete(m.weights, key)
+		for i := 0; i < m.replicas*weight; i++ {
 			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
-			m.keys = append(m.keys, hash)
-			m.hashmap[hash] = key
+			//哈希冲突时这个虚拟节点可能已经属于其它节点
+			if m.hashmap[hash] == key {
+				delete(m.hashmap, hash)
+				removed = true
+			}
 		}
 	}
-	sort.Ints(m.keys)
+	if !removed {
+		return
+	}
+	//只保留仍然存在的虚拟节点，m.keys 本身是有序的，过滤之后仍然有序
+	keys2 := m.keys[:0]
+	for _, hash := range m.keys {
+		if _, ok := m.hashmap[hash]; ok {
+			keys2 = append(keys2, hash)
+		}
+	}
+	m.keys = keys2
 }
 
 //选择合适的节点
@@ -60,3 +110,39 @@ func (m *Map) Get(key string) string {
 	//如果idx==len(m.keys)，说明应选择m.keys[0]，因为m.keys是一个环状结构，所以用取余数的方式
 	return m.hashmap[m.keys[idx%len(m.keys)]]
 }
+
+//从 key 的位置开始顺时针查找，返回最多 n 个不同的真实节点
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+	if n > len(m.weights) {
+		n = len(m.weights)
+	}
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool {
+		return m.keys[i] >= hash
+	})
+	return m.walk(idx, n, nil)
+}
+
+//从下标 idx 开始沿着环收集不同的真实节点，追加到 nodes 中，直到有 n 个
+func (m *Map) walk(idx, n int, nodes []string) []string {
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashmap[m.keys[(idx+i)%len(m.keys)]]
+		if !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+//副本的个数很少，直接遍历
+func contains(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
@@ -41,3 +41,98 @@ func TestHashing(t *testing.T) {
 	}
 
 }
+
+func TestRemove(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+	// 2, 4, 6, 8, 12, 14, 16, 18, 22, 24, 26, 28
+	hash.Add("6", "4", "2", "8")
+	hash.Remove("8")
+
+	testCases := map[string]string{
+		"2":  "2",
+		"11": "2",
+		"23": "4",
+		"27": "2",
+	}
+	for k, v := range testCases {
+		if hash.Get(k) != v {
+			t.Errorf("Asking for %s, should have yielded %s", k, v)
+		}
+	}
+	if len(hash.keys) != 9 || len(hash.hashmap) != 9 {
+		t.Fatalf("virtual nodes of 8 should be removed, %d left", len(hash.keys))
+	}
+}
+
+// 增加或删除一个节点时，只有大约 1/N 的 key 需要移动
+func TestRebalance(t *testing.T) {
+	const n, keys = 10, 10000
+	hash := New(50, nil)
+	for i := 0; i < n; i++ {
+		hash.Add("node" + strconv.Itoa(i))
+	}
+	before := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		k := "key" + strconv.Itoa(i)
+		before[k] = hash.Get(k)
+	}
+
+	hash.Add("node10")
+	moved := 0
+	for k, owner := range before {
+		if now := hash.Get(k); now != owner {
+			moved++
+			if now != "node10" {
+				t.Fatalf("%s moved from %s to %s, expect node10", k, owner, now)
+			}
+		}
+	}
+	if ratio := float64(moved) / keys; ratio < 0.5/(n+1) || ratio > 2.0/(n+1) {
+		t.Fatalf("%.3f of keys moved after adding a node, expect about %.3f", ratio, 1.0/(n+1))
+	}
+
+	hash.Remove("node10")
+	for k, owner := range before {
+		if now := hash.Get(k); now != owner {
+			t.Fatalf("%s should move back to %s after removing node10, got %s", k, owner, now)
+		}
+	}
+	hash.Remove("node3")
+	for k, owner := range before {
+		if now := hash.Get(k); now != owner && owner != "node3" {
+			t.Fatalf("%s owned by %s should not move after removing node3", k, owner)
+		}
+	}
+}
+
+func TestWeight(t *testing.T) {
+	hash := New(50, nil)
+	weights := map[string]int{"small": 1, "medium": 2, "large": 4}
+	total := 0
+	for node, w := range weights {
+		hash.AddWithWeight(node, w)
+		total += w
+	}
+
+	const n = 70000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[hash.Get("key"+strconv.Itoa(i))]++
+	}
+	// 每个节点分到的 key 和权重大致成正比
+	for node, w := range weights {
+		expect := float64(n*w) / float64(total)
+		if got := float64(counts[node]); got < expect*0.75 || got > expect*1.25 {
+			t.Errorf("node %s with weight %d got %d keys, expect about %.0f", node, w, counts[node], expect)
+		}
+	}
+
+	// 删除带权重的节点，它的虚拟节点全部删除
+	hash.Remove("large")
+	if len(hash.keys) != 50*3 {
+		t.Fatalf("expect %d virtual nodes, got %d", 50*3, len(hash.keys))
+	}
+}
@@ -0,0 +1,94 @@
+package consistenthash
+
+import "sort"
+
+// Jump 是 Lamping 和 Veach 提出的跳跃一致性哈希，不需要保存环，
+// 只用 O(ln n) 的计算把 key 映射到 [0, n) 中的一个桶。
+// 它只对在末尾增删桶是一致的：桶数从 n 变为 n+1 时只有 1/(n+1) 的 key 移动。
+// 为了让所有节点上的桶顺序一致，这里按节点名排序，所以在中间增删节点时移动的 key 会更多。
+// 权重为 w 的节点占 w 个桶
+type Jump struct {
+	//真实节点的权重
+	weights map[string]int
+	//按节点名排序之后展开的桶
+	buckets []string
+}
+
+func NewJump() *Jump {
+	return &Jump{weights: make(map[string]int)}
+}
+
+func (j *Jump) Add(keys ...string) {
+	for _, key := range keys {
+		j.weights[key] = 1
+	}
+	j.rebuild()
+}
+
+// 权重小于1时按1处理
+func (j *Jump) AddWithWeight(key string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	j.weights[key] = weight
+	j.rebuild()
+}
+
+func (j *Jump) Remove(keys ...string) {
+	for _, key := range keys {
+		delete(j.weights, key)
+	}
+	j.rebuild()
+}
+
+func (j *Jump) Get(key string) string {
+	if len(j.buckets) == 0 {
+		return ""
+	}
+	return j.buckets[jumpHash(hash64(key), len(j.buckets))]
+}
+
+// 第一个节点与 Get 相同，副本依次取后面的桶中不同的节点
+func (j *Jump) GetN(key string, n int) []string {
+	if len(j.buckets) == 0 || n <= 0 {
+		return nil
+	}
+	if n > len(j.weights) {
+		n = len(j.weights)
+	}
+	b := jumpHash(hash64(key), len(j.buckets))
+	var nodes []string
+	for i := 0; i < len(j.buckets) && len(nodes) < n; i++ {
+		node := j.buckets[(b+i)%len(j.buckets)]
+		if !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// 重新生成桶
+func (j *Jump) rebuild() {
+	nodes := make([]string, 0, len(j.weights))
+	for node := range j.weights {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	j.buckets = j.buckets[:0]
+	for _, node := range nodes {
+		for i := 0; i < j.weights[node]; i++ {
+			j.buckets = append(j.buckets, node)
+		}
+	}
+}
+
+// 论文中的算法，返回 [0, n) 中的桶号
+func jumpHash(key uint64, n int) int {
+	var b, i int64 = -1, 0
+	for i < int64(n) {
+		b = i
+		key = key*2862933555777941757 + 1
+		i = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
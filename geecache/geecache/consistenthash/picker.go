@@ -0,0 +1,38 @@
+package consistenthash
+
+import "hash/fnv"
+
+// Picker 把 key 映射到某个真实节点，所有节点上相同的节点列表必须得到相同的结果。
+// Map（一致性哈希环）、Rendezvous、Jump、Bounded 都实现了这个接口
+type Picker interface {
+	// 添加真实节点，权重都为1
+	Add(keys ...string)
+	// 添加一个带权重的真实节点，分到的 key 的数量大致和权重成正比
+	AddWithWeight(key string, weight int)
+	// 删除真实节点
+	Remove(keys ...string)
+	// 返回 key 对应的真实节点，没有节点时返回空字符串
+	Get(key string) string
+	// 返回 key 对应的最多 n 个不同的真实节点，第一个与 Get 相同，其余的依次作为副本
+	GetN(key string, n int) []string
+}
+
+var (
+	_ Picker = (*Map)(nil)
+	_ Picker = (*Rendezvous)(nil)
+	_ Picker = (*Jump)(nil)
+	_ Picker = (*Bounded)(nil)
+)
+
+// 64 位哈希，fnv 的低位分布不够均匀，再用 splitmix64 的收尾步骤打散
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,164 @@
+package consistenthash
+
+import (
+	"strconv"
+	"testing"
+)
+
+// 参与比较的几种算法
+func newPickers() map[string]func() Picker {
+	return map[string]func() Picker{
+		"ring":       func() Picker { return New(50, nil) },
+		"rendezvous": func() Picker { return NewRendezvous() },
+		"jump":       func() Picker { return NewJump() },
+		"bounded":    func() Picker { return NewBounded(50, nil, 0.25) },
+	}
+}
+
+func addNodes(p Picker, n int) {
+	for i := 0; i < n; i++ {
+		p.Add("node" + strconv.Itoa(i))
+	}
+}
+
+// 每个节点分到的 key 的个数，Bounded 把每个 key 算作一个长期的负载
+func distribute(p Picker, keys int) map[string]int {
+	counts := make(map[string]int)
+	b, bounded := p.(*Bounded)
+	for i := 0; i < keys; i++ {
+		node := p.Get("key" + strconv.Itoa(i))
+		counts[node]++
+		if bounded {
+			b.Inc(node)
+		}
+	}
+	return counts
+}
+
+func TestPickerDistribution(t *testing.T) {
+	const nodes, keys = 10, 100000
+	// 最多的节点分到的 key 与平均值的比值上限
+	limits := map[string]float64{"ring": 1.4, "rendezvous": 1.05, "jump": 1.05, "bounded": 1.25}
+	for name, newPicker := range newPickers() {
+		p := newPicker()
+		addNodes(p, nodes)
+		counts := distribute(p, keys)
+		if len(counts) != nodes {
+			t.Fatalf("%s: expect keys on %d nodes, got %d", name, nodes, len(counts))
+		}
+		most := 0
+		for _, c := range counts {
+			if c > most {
+				most = c
+			}
+		}
+		ratio := float64(most) / (keys / nodes)
+		t.Logf("%-10s max/avg = %.3f", name, ratio)
+		if ratio > limits[name] {
+			t.Errorf("%s: max/avg = %.3f, expect <= %.2f", name, ratio, limits[name])
+		}
+	}
+}
+
+func TestPickerWeight(t *testing.T) {
+	const keys = 60000
+	for name, newPicker := range newPickers() {
+		p := newPicker()
+		p.AddWithWeight("small", 1)
+		p.AddWithWeight("large", 2)
+		counts := distribute(p, keys)
+		// large 大约分到 2/3 的 key
+		if got := float64(counts["large"]) / keys; got < 0.6 || got > 0.73 {
+			t.Errorf("%s: large got %.3f of keys, expect about 0.667", name, got)
+		}
+	}
+}
+
+func TestPickerRemove(t *testing.T) {
+	const keys = 20000
+	for name, newPicker := range newPickers() {
+		if name == "bounded" {
+			continue
+		}
+		p := newPicker()
+		addNodes(p, 10)
+		before := make([]string, keys)
+		for i := range before {
+			before[i] = p.Get("key" + strconv.Itoa(i))
+		}
+		// 删除最后一个节点，按名字排序后 node9 也是 jump 的最后一个桶
+		p.Remove("node9")
+		for i, old := range before {
+			now := p.Get("key" + strconv.Itoa(i))
+			if now == "node9" {
+				t.Fatalf("%s: removed node should not be picked", name)
+			}
+			if old != "node9" && now != old {
+				t.Fatalf("%s: key%d moved from %s to %s", name, i, old, now)
+			}
+		}
+	}
+}
+
+func TestBoundedLoad(t *testing.T) {
+	b := NewBounded(50, nil, 0.25)
+	addNodes(b, 4)
+	// 同一个 key 反复请求，负载超过上限之后分散到其它节点
+	owner := b.Get("hot")
+	for i := 0; i < 100; i++ {
+		b.Inc(b.Get("hot"))
+	}
+	if load := b.Load(owner); load > 32 {
+		t.Fatalf("owner load %d exceeds (1+epsilon)*avg", load)
+	}
+	for i := 0; i < 100; i++ {
+		b.Done(owner)
+	}
+	if b.Get("hot") != owner {
+		t.Fatalf("expect key to go back to its owner when loads drop")
+	}
+}
+
+func benchmarkPicker(b *testing.B, newPicker func() Picker, nodes int) {
+	p := newPicker()
+	addNodes(p, nodes)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Get(keys[i&1023])
+	}
+}
+
+func BenchmarkPickers(b *testing.B) {
+	for _, nodes := range []int{10, 100} {
+		for _, name := range []string{"ring", "rendezvous", "jump", "bounded"} {
+			newPicker := newPickers()[name]
+			b.Run(name+"/"+strconv.Itoa(nodes), func(b *testing.B) {
+				benchmarkPicker(b, newPicker, nodes)
+			})
+		}
+	}
+}
+
+func TestPickerGetN(t *testing.T) {
+	for name, newPicker := range newPickers() {
+		p := newPicker()
+		addNodes(p, 5)
+		for i := 0; i < 100; i++ {
+			key := "key" + strconv.Itoa(i)
+			nodes := p.GetN(key, 3)
+			if len(nodes) != 3 || nodes[0] != p.Get(key) {
+				t.Fatalf("%s: GetN(%s, 3) = %v, first should be %s", name, key, nodes, p.Get(key))
+			}
+			if nodes[0] == nodes[1] || nodes[1] == nodes[2] || nodes[0] == nodes[2] {
+				t.Fatalf("%s: replicas should be distinct, got %v", name, nodes)
+			}
+		}
+		if nodes := p.GetN("key", 10); len(nodes) != 5 {
+			t.Fatalf("%s: expect at most 5 nodes, got %v", name, nodes)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package consistenthash
+
+import (
+	"math"
+	"sort"
+)
+
+// Rendezvous 是最高随机权重（HRW）哈希：对每个节点计算 hash(节点, key) 的得分，
+// 得分最高的节点负责这个 key。
+// 删除节点时只有该节点上的 key 会移动，不需要虚拟节点，分布也更均匀，
+// 代价是每次 Get 都要遍历所有节点，复杂度为 O(n)
+type Rendezvous struct {
+	//真实节点的权重
+	weights map[string]int
+}
+
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{weights: make(map[string]int)}
+}
+
+func (r *Rendezvous) Add(keys ...string) {
+	for _, key := range keys {
+		r.weights[key] = 1
+	}
+}
+
+// 权重小于1时按1处理
+func (r *Rendezvous) AddWithWeight(key string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	r.weights[key] = weight
+}
+
+func (r *Rendezvous) Remove(keys ...string) {
+	for _, key := range keys {
+		delete(r.weights, key)
+	}
+}
+
+// 选择得分最高的节点，得分相同时选择名字较小的，保证结果与遍历顺序无关
+func (r *Rendezvous) Get(key string) string {
+	var best string
+	bestScore := math.Inf(-1)
+	for node, weight := range r.weights {
+		score := r.score(node, weight, key)
+		if score > bestScore || (score == bestScore && node < best) {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+// 按得分从高到低返回最多 n 个节点
+func (r *Rendezvous) GetN(key string, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	type scored struct {
+		node  string
+		score float64
+	}
+	all := make([]scored, 0, len(r.weights))
+	for node, weight := range r.weights {
+		all = append(all, scored{node, r.score(node, weight, key)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].node < all[j].node
+	})
+	if n > len(all) {
+		n = len(all)
+	}
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = all[i].node
+	}
+	return nodes
+}
+
+// 带权重的得分 -w/ln(u)，u 是 (0,1) 上均匀分布的哈希值，
+// 这样每个节点胜出的概率正好和权重成正比
+func (r *Rendezvous) score(node string, weight int, key string) float64 {
+	h := hash64(node + "\x00" + key)
+	u := (float64(h>>11) + 0.5) / (1 << 53)
+	return -float64(weight) / math.Log(u)
+}
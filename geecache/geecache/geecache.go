@@ -1,12 +1,22 @@
 package geecache
 
 import (
+	"context"
+	"errors"
 	pb "geecache/geecachepb"
 	"geecache/singleflight"
 	"log"
+	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrNotFound 表示数据源中确定没有这个 key，Getter 返回它（或者用 %w 包装它）时，
+// 开启了 WithNegativeTTL 的 Group 会把这个结果缓存一段时间，其它错误视为暂时的失败，不会缓存
+var ErrNotFound = errors.New("geecache: key not found")
+
 type Getter interface {
 	Get(key string) ([]byte, error)
 }
@@ -18,17 +28,180 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// TTLGetter 在返回数据的同时指定这条数据的过期时间，覆盖 Group 的默认值
+// 返回的 ttl <= 0 时使用 Group 的默认过期时间
+type TTLGetter interface {
+	Getter
+	GetWithTTL(key string) ([]byte, time.Duration, error)
+}
+
+// ContextGetter 可以通过 ctx 取消或者限制回调的执行时间，
+// Group.GetContext 的 ctx 会一直传递到这里
+type ContextGetter interface {
+	Getter
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+// 实现了Getter接口的Get方法，使用 context.Background()
+func (f ContextGetterFunc) Get(key string) ([]byte, error) {
+	return f(context.Background(), key)
+}
+
+// 实现了ContextGetter接口的GetContext方法
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+type TTLGetterFunc func(key string) ([]byte, time.Duration, error)
+
+// 实现了Getter接口的Get方法，忽略返回的ttl
+func (f TTLGetterFunc) Get(key string) ([]byte, error) {
+	bytes, _, err := f(key)
+	return bytes, err
+}
+
+// 实现了TTLGetter接口的GetWithTTL方法
+func (f TTLGetterFunc) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	return f(key)
+}
+
+// ContextTTLGetter 同时接收 ctx 并指定过期时间，同时实现了 ContextGetter 和 TTLGetter 时优先使用它
+type ContextTTLGetter interface {
+	ContextGetter
+	TTLGetter
+	GetWithTTLContext(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
+
+type ContextTTLGetterFunc func(ctx context.Context, key string) ([]byte, time.Duration, error)
+
+// 实现了Getter接口的Get方法，使用 context.Background()，忽略返回的ttl
+func (f ContextTTLGetterFunc) Get(key string) ([]byte, error) {
+	bytes, _, err := f(context.Background(), key)
+	return bytes, err
+}
+
+// 实现了ContextGetter接口的GetContext方法，忽略返回的ttl
+func (f ContextTTLGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	bytes, _, err := f(ctx, key)
+	return bytes, err
+}
+
+// 实现了TTLGetter接口的GetWithTTL方法，使用 context.Background()
+func (f ContextTTLGetterFunc) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	return f(context.Background(), key)
+}
+
+// 实现了ContextTTLGetter接口的GetWithTTLContext方法
+func (f ContextTTLGetterFunc) GetWithTTLContext(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	return f(ctx, key)
+}
+
 //看做一个缓存的命名空间
 type Group struct {
 	name string
 	//缓存未命中时的回调函数
 	getter Getter
-	//缓存
+	//内存上限，mainCache、hotCache 和负缓存按比例分配，原子地读写
+	cacheBytes int64
+	//缓存，保存本节点作为拥有者的数据
 	mainCache cache
-	peers     PeerPicker
-	loader    *singleflight.Group
+	//热点缓存，保存从其它节点取回的一部分数据，避免热点 key 每次都要经过网络
+	hotCache cache
+	//负缓存，保存 Getter 返回 ErrNotFound 的 key，值为空
+	negCache cache
+	peers    PeerPicker
+	loader   *singleflight.Group
+	//缓存的默认过期时间，0 表示永不过期
+	ttl time.Duration
+	//负缓存的过期时间，0 表示不开启负缓存
+	negativeTTL time.Duration
+	//读取时依次尝试的节点个数，包括拥有者
+	readReplicas int
+	//写入时写到的节点个数，包括拥有者
+	writeReplicas int
+	//压缩算法，nil 表示不压缩，以及开始压缩的大小
+	codec             Codec
+	compressThreshold int
+	//从其它节点和 Getter 载入的耗时
+	peerLatency, localLatency histogram
+	//快照文件，为空表示不自动恢复和保存
+	snapshotFile string
+	//统计数据
+	Stats Stats
+}
+
+// GroupOption 用来在 NewGroup 时修改 Group 的可选配置
+type GroupOption func(*Group)
+
+// 设置缓存的默认过期时间
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// 设置缓存的淘汰策略，默认是 LRU
+func WithEvictionPolicy(policy EvictionPolicy) GroupOption {
+	return func(g *Group) {
+		g.mainCache.evictionPolicy = policy
+		g.hotCache.evictionPolicy = policy
+	}
+}
+
+// 把缓存分成 n 个分片，减少并发访问时的锁竞争，每个分片的内存上限是 cacheBytes/n
+func WithShards(n int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.nshards = n
+		g.hotCache.nshards = n
+	}
 }
 
+// 设置后台清理过期条目的间隔
+func WithJanitorInterval(interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.mainCache.janitorInterval = interval
+		g.hotCache.janitorInterval = interval
+		g.negCache.janitorInterval = interval
+	}
+}
+
+// 开启负缓存：Getter 返回 ErrNotFound 的 key 在 ttl 内直接返回 ErrNotFound，不再访问数据源，
+// ttl 应该比较短，因为数据源中新增的 key 在过期之前都查不到，Set 和 Remove 会立即清除负缓存
+func WithNegativeTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.negativeTTL = ttl
+	}
+}
+
+// 设置读取时依次尝试的节点个数（包括拥有者），拥有者不可用时从下一个副本读取，
+// 轮到自己时才调用 Getter，默认为 2
+func WithReadReplicas(n int) GroupOption {
+	return func(g *Group) {
+		g.readReplicas = n
+	}
+}
+
+// 设置 Set 写入的节点个数（包括拥有者），默认为 1，只写入拥有者。
+// 节点使用 consistenthash.NewBounded 时拥有者随负载变化，不要使用 Set 和这个选项
+func WithWriteReplicas(n int) GroupOption {
+	return func(g *Group) {
+		g.writeReplicas = n
+	}
+}
+
+const (
+	//读取时默认依次尝试拥有者和下一个副本
+	defaultReadReplicas = 2
+	//热点缓存占 cacheBytes 的比例为 1/hotCacheRatio
+	hotCacheRatio = 8
+	//从其它节点取回的数据，每 hotCacheSampleRate 次放入一次热点缓存
+	hotCacheSampleRate = 10
+	//负缓存只保存 key，内存上限为 cacheBytes/negativeCacheRatio，不占用 mainCache 和 hotCache 的内存
+	negativeCacheRatio = 16
+)
+
 //全局变量
 var (
 	mu sync.RWMutex
@@ -36,17 +209,34 @@ var (
 	groups = make(map[string]*Group)
 )
 
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
+	group := &Group{
+		name:       name,
+		getter:     getter,
+		cacheBytes: cacheBytes,
+		mainCache:  cache{cacheBytes: cacheBytes - cacheBytes/hotCacheRatio},
+		hotCache:   cache{cacheBytes: fraction(cacheBytes, hotCacheRatio)},
+		negCache:   cache{cacheBytes: fraction(cacheBytes, negativeCacheRatio)},
+		loader:     &singleflight.Group{},
+
+		readReplicas:  defaultReadReplicas,
+		writeReplicas: 1,
+	}
+	for _, opt := range opts {
+		opt(group)
+	}
+	//恢复快照需要读文件，在加锁之前完成，不阻塞 GetGroup
+	if group.snapshotFile != "" {
+		group.loadSnapshot()
+	}
 	mu.Lock()
 	defer mu.Unlock()
-	group := &Group{
-		name:      name,
-		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
-		loader:    &singleflight.Group{},
+	//同名的 Group 已经存在，通常是重复调用了 NewGroup，直接替换会丢掉原来的缓存
+	if _, ok := groups[name]; ok {
+		panic("duplicate registration of group " + name)
 	}
 	groups[name] = group
 	return group
@@ -60,17 +250,91 @@ func GetGroup(name string) *Group {
 	return g
 }
 
+// ListGroups 返回所有 Group 的名称，按名称排序
+func ListGroups() []string {
+	mu.RLock()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// DestroyGroup 删除指定名称的 Group 并释放它的缓存，返回 Group 是否存在。
+// 之后可以用相同的名称重新 NewGroup，仍然持有旧 Group 的调用方只能从数据源载入
+func DestroyGroup(name string) bool {
+	mu.Lock()
+	g, ok := groups[name]
+	delete(groups, name)
+	mu.Unlock()
+	if !ok {
+		return false
+	}
+	g.mainCache.close()
+	g.hotCache.close()
+	g.negCache.close()
+	return true
+}
+
+// CacheBytes 返回当前的内存上限
+func (g *Group) CacheBytes() int64 {
+	return atomic.LoadInt64(&g.cacheBytes)
+}
+
+// SetCacheBytes 在运行时修改内存上限，按照 NewGroup 时的比例分给 mainCache、hotCache 和负缓存，
+// 变小时立即淘汰多出来的条目
+func (g *Group) SetCacheBytes(cacheBytes int64) {
+	atomic.StoreInt64(&g.cacheBytes, cacheBytes)
+	g.mainCache.resize(cacheBytes - cacheBytes/hotCacheRatio)
+	g.hotCache.resize(fraction(cacheBytes, hotCacheRatio))
+	g.negCache.resize(fraction(cacheBytes, negativeCacheRatio))
+}
+
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 和 Get 相同，ctx 会传递给 ContextGetter 和远程节点，
+// ctx 结束后不再等待加载的结果，也不再尝试其它节点
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
+	g.Stats.Gets.Add(1)
 	//空key
 	if key == "" {
 		return ByteView{}, nil
 	}
 	//从缓存中获取
-	if v, ok := g.mainCache.get(key); ok {
-		return v, nil
+	if v, err, ok := g.lookupCache(key); ok {
+		return v, err
 	}
 	//缓存未命中，调用load方法，载入数据
-	return g.load(key)
+	return g.load(ctx, key, nil)
+}
+
+//依次查找 mainCache、hotCache 和负缓存，ok 为 false 表示需要载入
+func (g *Group) lookupCache(key string) (value ByteView, err error, ok bool) {
+	for _, c := range []*cache{&g.mainCache, &g.hotCache} {
+		if v, ok := c.get(key); ok {
+			v, err := v.decompress()
+			if err != nil {
+				//解压失败，当作没有命中，重新载入
+				log.Println("[GeeCache] Failed to decompress", err)
+				c.remove(key)
+				continue
+			}
+			g.Stats.CacheHits.Add(1)
+			return v, nil, true
+		}
+	}
+	//最近确认过数据源中没有这个 key
+	if g.negativeTTL > 0 {
+		if _, ok := g.negCache.get(key); ok {
+			g.Stats.NegativeHits.Add(1)
+			return ByteView{}, ErrNotFound, true
+		}
+	}
+	return ByteView{}, nil, false
 }
 
 // func (g *Group) load(key string) (value ByteView, err error) {
@@ -79,48 +343,259 @@ func (g *Group) Get(key string) (ByteView, error) {
 // 	return g.getLocally(key)
 // }
 
-func (g *Group) load(key string) (value ByteView, err error) {
-	//使用Do方法，确保每个key只被请求一次
-	viewi, err := g.loader.Do(key, func() (interface{}, error) {
-
-		if g.peers != nil {
-			if peer, ok := g.peers.PickPeer(key); ok {
-				//从远程节点获取
-				if value, err = g.getFromPeer(peer, key); err == nil {
-					return value, nil
-				}
-				//远程节点没有，则可能是本机节点，或者缓存失效，从本机获取调用getLocally来验证
-				log.Println("[GeeCache] Failed to get from peer", err)
+//载入缓存中没有的 key，failed 是刚刚请求失败的节点，载入时跳过它，nil 表示不跳过
+func (g *Group) load(ctx context.Context, key string, failed PeerGetter) (ByteView, error) {
+	g.Stats.Loads.Add(1)
+	for {
+		//是否由自己执行了载入
+		leader := false
+		//使用DoContext方法，确保每个key只被请求一次
+		viewi, err, _ := g.loader.DoContext(ctx, key, func() (interface{}, error) {
+			leader = true
+			v, err := g.loadOnce(ctx, key, failed)
+			if err != nil && ctx.Err() != nil {
+				err = &leaderCtxError{err}
+			}
+			return v, err
+		})
+		if err == nil {
+			//类型断言
+			return viewi.(ByteView), nil
+		}
+		//合并的载入使用的是第一个调用方的 ctx，它超时或者被取消时自己的 ctx 可能还没有结束，
+		//这时重新载入，而不是把别人的 ctx 错误返回给自己的调用方
+		var lerr *leaderCtxError
+		if errors.As(err, &lerr) {
+			if !leader && ctx.Err() == nil {
+				continue
 			}
+			err = lerr.err
 		}
-		return g.getLocally(key)
-	})
-	if err == nil {
-		//类型断言
-		return viewi.(ByteView), nil
+		return ByteView{}, err
 	}
-	return
+}
+
+//合并的载入因为执行它的调用方的 ctx 结束而失败，等待的调用方据此重新载入，
+//Getter 自己的超时等其它错误不会被包装
+type leaderCtxError struct {
+	err error
+}
+
+func (e *leaderCtxError) Error() string {
+	return e.err.Error()
+}
+
+func (e *leaderCtxError) Unwrap() error {
+	return e.err
+}
+
+//真正的载入，依次尝试拥有者和副本，最后调用 Getter，由 singleflight 保证同一个 key 同时只有一个
+func (g *Group) loadOnce(ctx context.Context, key string, failed PeerGetter) (interface{}, error) {
+	g.Stats.LoadsDeduped.Add(1)
+	//其它节点转发来的请求只在本机载入
+	if g.peers != nil && !isForwarded(ctx) {
+		//依次尝试拥有者和副本，轮到自己时从本机获取，避免拥有者不可用时所有节点都去访问数据源
+		for _, peer := range g.peers.PickPeers(key, g.readReplicas) {
+			if peer == nil {
+				break
+			}
+			if peer == failed {
+				continue
+			}
+			//从远程节点获取
+			start := time.Now()
+			value, err := g.getFromPeer(ctx, peer, key)
+			g.peerLatency.observe(time.Since(start))
+			if err == nil {
+				g.Stats.PeerLoads.Add(1)
+				return value, nil
+			}
+			//远程节点确认数据源中没有这个 key，负缓存由远程节点保存
+			if errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			g.Stats.PeerErrors.Add(1)
+			//远程节点没有，则可能是本机节点，或者缓存失效，尝试下一个副本，最后调用getLocally来验证
+			log.Println("[GeeCache] Failed to get from peer", err)
+			//已经超时或者被取消，不再尝试其它节点
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		}
+	}
+	start := time.Now()
+	value, err := g.getLocally(ctx, key)
+	g.localLatency.observe(time.Since(start))
+	if err != nil {
+		g.Stats.LocalLoadErrs.Add(1)
+		if g.negativeTTL > 0 && errors.Is(err, ErrNotFound) {
+			g.negCache.add(key, ByteView{}, time.Now().Add(g.negativeTTL))
+		}
+		return nil, err
+	}
+	g.Stats.LocalLoads.Add(1)
+	return value, nil
 }
 
 //分布式环境下会调用getFromPeer从其他节点获取缓存
-func (g *Group) getLocally(key string) (ByteView, error) {
-	//获取并调用用户回调函数
-	bytes, err := g.getter.Get(key)
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	//获取并调用用户回调函数，TTLGetter 可以单独指定过期时间，ContextGetter 可以接收 ctx，
+	//ContextTTLGetter 两者都可以，同时实现了多个接口时优先传递 ctx
+	var (
+		bytes []byte
+		ttl   time.Duration
+		err   error
+	)
+	switch getter := g.getter.(type) {
+	case ContextTTLGetter:
+		bytes, ttl, err = getter.GetWithTTLContext(ctx, key)
+	case ContextGetter:
+		bytes, err = getter.GetContext(ctx, key)
+	case TTLGetter:
+		bytes, ttl, err = getter.GetWithTTL(key)
+	default:
+		bytes, err = g.getter.Get(key)
+	}
 	if err != nil {
 		//没有对应数据
 		return ByteView{}, err
 	}
 	//使用ByteView封装数据值
-	value := ByteView{b: cloneBytes(bytes)}
+	value := ByteView{b: cloneBytes(bytes), expire: g.expireAt(ttl)}
 	//将缓存值添加到缓存中
-	g.populateCache(key, value)
+	g.populateCache(key, value, value.expire)
 	return value, nil
 }
 
-//将数据添加到缓存中
-func (g *Group) populateCache(key string, value ByteView) {
+//将数据添加到缓存中，expire 为零值表示永不过期
+func (g *Group) populateCache(key string, value ByteView, expire time.Time) {
 	//将缓存值添加到缓存中
-	g.mainCache.add(key, value)
+	g.mainCache.add(key, g.compress(value), expire)
+	//key 已经存在，清除负缓存
+	if g.negativeTTL > 0 {
+		g.negCache.remove(key)
+	}
+}
+
+//计算过期时间点，零值表示永不过期
+func (g *Group) expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		ttl = g.ttl
+	}
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// Set 把已知的最新值写入 key 的拥有者节点，WithWriteReplicas 大于 1 时同时写入副本，
+// 使用 Group 的默认过期时间，某个节点写入失败时仍然写入其它节点，返回第一个错误
+func (g *Group) Set(key string, value []byte) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+	view := ByteView{b: cloneBytes(value)}
+	expire := g.expireAt(0)
+	var peers []PeerGetter
+	if g.peers != nil {
+		peers = g.peers.PickPeers(key, g.writeReplicas)
+	}
+	if len(peers) == 0 {
+		g.populateCache(key, view, expire)
+		return nil
+	}
+	//本地热点缓存中的旧值已经失效
+	g.hotCache.remove(key)
+	var firstErr error
+	for _, peer := range peers {
+		if peer == nil {
+			//自己是拥有者或者副本，直接写入本地缓存
+			g.populateCache(key, view, expire)
+			continue
+		}
+		if err := g.setToPeer(peer, key, view, expire); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+//写入远程节点的缓存
+func (g *Group) setToPeer(peer PeerGetter, key string, value ByteView, expire time.Time) error {
+	req := &pb.SetRequest{
+		Group:  g.name,
+		Key:    key,
+		Value:  value.b,
+		Expire: unixNano(expire),
+	}
+	return peer.Set(req)
+}
+
+//把过期时间转换为请求和响应中的 UnixNano，零值转换为 0
+func unixNano(expire time.Time) int64 {
+	if expire.IsZero() {
+		return 0
+	}
+	return expire.UnixNano()
+}
+
+//把请求和响应中的 UnixNano 转换为过期时间，0 表示永不过期
+func unixNanoTime(expire int64) time.Time {
+	if expire == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, expire)
+}
+
+// Remove 从整个集群中删除 key：先通知拥有者节点，再删除本地缓存，
+// 最后通知其它所有节点，因为它们可能还保留着旧的副本。
+// 某个节点删除失败时仍然删除其它节点，返回第一个错误
+func (g *Group) Remove(key string) error {
+	if g.peers == nil {
+		g.removeLocally(key)
+		return nil
+	}
+	req := &pb.Request{
+		Group: g.name,
+		Key:   key,
+	}
+	var err error
+	owner, ok := g.peers.PickPeer(key)
+	if ok {
+		err = owner.Remove(req)
+	}
+	g.removeLocally(key)
+
+	var wg sync.WaitGroup
+	peers := g.peers.GetAll()
+	errs := make(chan error, len(peers))
+	for _, peer := range peers {
+		if ok && peer == owner {
+			continue
+		}
+		wg.Add(1)
+		go func(peer PeerGetter) {
+			defer wg.Done()
+			errs <- peer.Remove(req)
+		}(peer)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		if err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//只删除本节点的缓存
+func (g *Group) removeLocally(key string) {
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+	if g.negativeTTL > 0 {
+		g.negCache.remove(key)
+	}
 }
 
 func (g *Group) RegisterPeers(peers PeerPicker) {
@@ -133,7 +608,7 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 }
 
 //从远程节点获取
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
 	//创建一个字节切片，用来存储获取到的数据
 	//本地直接get，这里要使用peer的get方法，使用http客户端
 	// bytes, err := peer.Get(g.name, key)
@@ -150,9 +625,19 @@ func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 		Key:   key,
 	}
 	res := &pb.Response{}
-	err := peer.Get(req, res)
+	err := peer.Get(ctx, req, res)
 	if err != nil {
 		return ByteView{}, err
 	}
-	return ByteView{b: res.Value}, nil
+	value := ByteView{b: res.Value, expire: unixNanoTime(res.GetExpire())}
+	g.sampleHotCache(key, value)
+	return value, nil
+}
+
+//从其它节点取回的数据只抽样放入一部分，热点 key 被访问得多，总会被放进去，
+//过期时间和拥有者节点上的相同
+func (g *Group) sampleHotCache(key string, value ByteView) {
+	if rand.Intn(hotCacheSampleRate) == 0 {
+		g.hotCache.add(key, g.compress(value), value.expire)
+	}
 }
@@ -1,10 +1,18 @@
 package geecache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	pb "geecache/geecachepb"
 	"log"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGetter(t *testing.T) {
@@ -56,3 +64,624 @@ func TestGet(t *testing.T) {
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+func TestTTL(t *testing.T) {
+	loads := 0
+	gee := NewGroup("ttl", 2<<10, TTLGetterFunc(
+		func(key string) ([]byte, time.Duration, error) {
+			loads++
+			if key == "Long" {
+				// 单独指定过期时间，覆盖 Group 的默认值
+				return []byte(key), time.Hour, nil
+			}
+			return []byte(key), 0, nil
+		}), WithTTL(20*time.Millisecond))
+
+	for _, k := range []string{"Tom", "Long"} {
+		if _, err := gee.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gee.Get("Tom"); err != nil || loads != 2 {
+		t.Fatalf("cache Tom miss before expiration, loads = %d", loads)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := gee.Get("Tom"); err != nil || loads != 3 {
+		t.Fatalf("expired Tom should be loaded again, loads = %d", loads)
+	}
+	if _, err := gee.Get("Long"); err != nil || loads != 3 {
+		t.Fatalf("Long should not expire with its own ttl, loads = %d", loads)
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	gee := NewGroup("context", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			select {
+			case <-time.After(time.Second):
+				return []byte(key), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := gee.GetContext(ctx, "Tom"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+	if _, ok := gee.mainCache.get("Tom"); ok {
+		t.Fatalf("cancelled load should not be cached")
+	}
+}
+
+func TestGetWithTTLContext(t *testing.T) {
+	loads := 0
+	gee := NewGroup("context_ttl", 2<<10, ContextTTLGetterFunc(
+		func(ctx context.Context, key string) ([]byte, time.Duration, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+			loads++
+			return []byte(key), 20 * time.Millisecond, nil
+		}), WithTTL(time.Hour))
+
+	// ctx 和过期时间都要生效
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := gee.GetContext(ctx, "Tom"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect Canceled, got %v", err)
+	}
+	if _, err := gee.Get("Tom"); err != nil || loads != 1 {
+		t.Fatalf("failed to load Tom, loads = %d", loads)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := gee.Get("Tom"); err != nil || loads != 2 {
+		t.Fatalf("Tom should expire with its own ttl, loads = %d", loads)
+	}
+}
+
+// 同时实现了 ContextGetter 和 TTLGetter，但没有实现 ContextTTLGetter
+type ctxAndTTLGetter struct {
+	gotCtx bool
+}
+
+func (g *ctxAndTTLGetter) Get(key string) ([]byte, error) {
+	return []byte(key), nil
+}
+
+func (g *ctxAndTTLGetter) GetContext(ctx context.Context, key string) ([]byte, error) {
+	g.gotCtx = true
+	return []byte(key), nil
+}
+
+func (g *ctxAndTTLGetter) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	return []byte(key), 0, nil
+}
+
+func TestGetterPrefersContext(t *testing.T) {
+	getter := &ctxAndTTLGetter{}
+	gee := NewGroup("prefers_context", 2<<10, getter)
+	if _, err := gee.Get("Tom"); err != nil || !getter.gotCtx {
+		t.Fatalf("getter implementing ContextGetter should receive ctx, err = %v", err)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	loads := 0
+	gee := NewGroup("negative", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			if key == "Flaky" {
+				return nil, fmt.Errorf("db timeout")
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}), WithNegativeTTL(50*time.Millisecond))
+
+	for i := 0; i < 3; i++ {
+		if _, err := gee.Get("Unknown"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expect ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 || gee.Stats.NegativeHits.Get() != 2 {
+		t.Fatalf("not found result should be cached, loads = %d", loads)
+	}
+
+	// 暂时的失败不会被缓存
+	gee.Get("Flaky")
+	gee.Get("Flaky")
+	if loads != 3 {
+		t.Fatalf("transient errors should not be cached, loads = %d", loads)
+	}
+
+	// Set 之后立即可见
+	if err := gee.Set("Unknown", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := gee.Get("Unknown"); err != nil || view.String() != "1" {
+		t.Fatalf("Set should clear negative cache, got %q, %v", view.String(), err)
+	}
+
+	// 负缓存过期之后重新访问数据源
+	gee.Get("Missing")
+	time.Sleep(60 * time.Millisecond)
+	gee.Get("Missing")
+	if loads != 5 {
+		t.Fatalf("expired negative entry should be reloaded, loads = %d", loads)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	c := &cache{cacheBytes: 2 << 10, janitorInterval: 10 * time.Millisecond}
+	c.add("k1", ByteView{b: []byte("v1")}, time.Now().Add(5*time.Millisecond))
+	c.add("k2", ByteView{b: []byte("v2")}, time.Time{})
+	time.Sleep(30 * time.Millisecond)
+	if n := c.stats().Items; n != 1 {
+		t.Fatalf("janitor should remove expired k1, %d entries left", n)
+	}
+	close(c.stop)
+}
+
+// 用于测试的假节点，记录收到的请求
+type fakePeer struct {
+	mu      sync.Mutex
+	name    string
+	gets    int
+	batches int
+	removed []string
+	sets    map[string]string
+	// 模拟节点不可用
+	down bool
+}
+
+func (p *fakePeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets++
+	if p.down {
+		return fmt.Errorf("peer %s is down", p.name)
+	}
+	out.Value = []byte(p.name + ":" + in.GetKey())
+	return nil
+}
+
+func (p *fakePeer) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.batches++
+	if p.down {
+		return fmt.Errorf("peer %s is down", p.name)
+	}
+	for _, key := range in.GetKeys() {
+		out.Results = append(out.Results, &pb.BatchResult{Key: key, Value: []byte(p.name + ":" + key)})
+	}
+	return nil
+}
+
+func (p *fakePeer) Remove(in *pb.Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removed = append(p.removed, in.GetKey())
+	if p.down {
+		return fmt.Errorf("peer %s is down", p.name)
+	}
+	return nil
+}
+
+func (p *fakePeer) Set(in *pb.SetRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return fmt.Errorf("peer %s is down", p.name)
+	}
+	if p.sets == nil {
+		p.sets = make(map[string]string)
+	}
+	p.sets[in.GetKey()] = string(in.GetValue())
+	return nil
+}
+
+// 用于测试的假 PeerPicker，owner 为 nil 时表示自己就是拥有者，
+// replicas 不为空时按顺序作为 PickPeers 的结果，其中的 nil 表示自己
+type fakePicker struct {
+	owner    *fakePeer
+	all      []*fakePeer
+	replicas []*fakePeer
+}
+
+func (p *fakePicker) PickPeer(key string) (PeerGetter, bool) {
+	if p.owner == nil {
+		return nil, false
+	}
+	return p.owner, true
+}
+
+func (p *fakePicker) PickPeers(key string, n int) []PeerGetter {
+	replicas := p.replicas
+	if replicas == nil {
+		replicas = []*fakePeer{p.owner}
+	}
+	var getters []PeerGetter
+	for i := 0; i < n && i < len(replicas); i++ {
+		if replicas[i] == nil {
+			getters = append(getters, nil)
+		} else {
+			getters = append(getters, replicas[i])
+		}
+	}
+	return getters
+}
+
+func (p *fakePicker) GetAll() []PeerGetter {
+	getters := make([]PeerGetter, 0, len(p.all))
+	for _, peer := range p.all {
+		getters = append(getters, peer)
+	}
+	return getters
+}
+
+func TestRemove(t *testing.T) {
+	loads := 0
+	gee := NewGroup("remove", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	peers := []*fakePeer{{name: "a"}, {name: "b"}}
+	gee.RegisterPeers(&fakePicker{all: peers})
+
+	if _, err := gee.Get("Tom"); err != nil || loads != 1 {
+		t.Fatalf("failed to load Tom")
+	}
+	if err := gee.Remove("Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gee.Get("Tom"); err != nil || loads != 2 {
+		t.Fatalf("removed Tom should be loaded again, loads = %d", loads)
+	}
+	for _, peer := range peers {
+		if !reflect.DeepEqual(peer.removed, []string{"Tom"}) {
+			t.Fatalf("peer %s should receive remove of Tom, got %v", peer.name, peer.removed)
+		}
+	}
+}
+
+func TestRemoveOwnerDown(t *testing.T) {
+	loads := 0
+	gee := NewGroup("remove_owner_down", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	owner, other := &fakePeer{name: "owner", down: true}, &fakePeer{name: "other"}
+	picker := &fakePicker{all: []*fakePeer{owner, other}}
+	gee.RegisterPeers(picker)
+	if _, err := gee.Get("Tom"); err != nil || loads != 1 {
+		t.Fatalf("failed to load Tom")
+	}
+
+	// 拥有者删除失败时仍然删除本地缓存并通知其它节点
+	picker.owner = owner
+	if err := gee.Remove("Tom"); err == nil {
+		t.Fatal("Remove should return the owner's error")
+	}
+	if _, ok := gee.mainCache.get("Tom"); ok {
+		t.Fatal("Tom should be removed locally")
+	}
+	if !reflect.DeepEqual(other.removed, []string{"Tom"}) || len(owner.removed) != 1 {
+		t.Fatalf("owner removed %v, other removed %v, want one remove each", owner.removed, other.removed)
+	}
+}
+
+func TestSmallCacheBytes(t *testing.T) {
+	gee := NewGroup("small_cache_bytes", 4, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	// cacheBytes 小于 hotCacheRatio 时热点缓存仍然有上限
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		gee.hotCache.add(k, ByteView{b: []byte(k)}, time.Time{})
+	}
+	if b := gee.CacheStats(HotCache).Bytes; b > defaultShards {
+		t.Fatalf("hot cache with 4 bytes grew to %d bytes", b)
+	}
+	gee.SetCacheBytes(1 << 10)
+	gee.SetCacheBytes(4)
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		gee.hotCache.add(k, ByteView{b: []byte(k)}, time.Time{})
+	}
+	if b := gee.CacheStats(HotCache).Bytes; b > defaultShards {
+		t.Fatalf("hot cache resized to 4 bytes grew to %d bytes", b)
+	}
+	// 负缓存也一样
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		gee.negCache.add(k, ByteView{}, time.Now().Add(time.Minute))
+	}
+	if b := gee.negCache.stats().Bytes; b > defaultShards {
+		t.Fatalf("negative cache resized to 4 bytes grew to %d bytes", b)
+	}
+}
+
+func TestSet(t *testing.T) {
+	loads := 0
+	gee := NewGroup("set", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	picker := &fakePicker{}
+	gee.RegisterPeers(picker)
+
+	// 自己是拥有者，直接写入本地缓存
+	if err := gee.Set("Tom", []byte("630")); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := gee.Get("Tom"); err != nil || view.String() != "630" || loads != 0 {
+		t.Fatalf("Set Tom locally failed, loads = %d", loads)
+	}
+
+	// 拥有者是远程节点，写入远程节点
+	picker.owner = &fakePeer{name: "a"}
+	if err := gee.Set("Jack", []byte("589")); err != nil {
+		t.Fatal(err)
+	}
+	if picker.owner.sets["Jack"] != "589" {
+		t.Fatalf("Set Jack should be sent to owner, got %v", picker.owner.sets)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	loads := 0
+	gee := NewGroup("failover", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	a, b := &fakePeer{name: "a", down: true}, &fakePeer{name: "b"}
+	gee.RegisterPeers(&fakePicker{replicas: []*fakePeer{a, b, nil}})
+
+	// 拥有者不可用，从下一个副本读取
+	if view, err := gee.Get("Tom"); err != nil || view.String() != "b:Tom" || loads != 0 {
+		t.Fatalf("expect Tom from replica b, got %q, loads = %d", view.String(), loads)
+	}
+	// 副本也不可用，才从本机获取
+	b.down = true
+	if view, err := gee.Get("Jack"); err != nil || view.String() != "Jack" || loads != 1 {
+		t.Fatalf("expect Jack loaded locally, got %q, loads = %d", view.String(), loads)
+	}
+}
+
+func TestWriteReplicas(t *testing.T) {
+	gee := NewGroup("write_replicas", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist", key)
+		}), WithWriteReplicas(3))
+	a, b := &fakePeer{name: "a"}, &fakePeer{name: "b"}
+	gee.RegisterPeers(&fakePicker{replicas: []*fakePeer{a, nil, b}})
+
+	if err := gee.Set("Tom", []byte("630")); err != nil {
+		t.Fatal(err)
+	}
+	if a.sets["Tom"] != "630" || b.sets["Tom"] != "630" {
+		t.Fatalf("Set should be sent to all replicas, a=%v b=%v", a.sets, b.sets)
+	}
+	if view, ok := gee.mainCache.get("Tom"); !ok || view.String() != "630" {
+		t.Fatalf("Set should be written locally when self is a replica")
+	}
+}
+
+func TestHotCache(t *testing.T) {
+	gee := NewGroup("hot", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s should be loaded from peer", key)
+		}))
+	owner := &fakePeer{name: "a"}
+	gee.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	// 抽样放入热点缓存，多次请求之后一定会命中
+	for i := 0; i < 200; i++ {
+		view, err := gee.Get("Tom")
+		if err != nil || view.String() != "a:Tom" {
+			t.Fatalf("failed to get Tom from peer: %v", err)
+		}
+	}
+	if owner.gets >= 200 {
+		t.Fatalf("hot key Tom should be served from hotCache, peer gets = %d", owner.gets)
+	}
+	if _, ok := gee.mainCache.get("Tom"); ok {
+		t.Fatalf("value from peer should not be stored in mainCache")
+	}
+
+	if err := gee.Remove("Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gee.hotCache.get("Tom"); ok {
+		t.Fatalf("Remove should clear hotCache")
+	}
+}
+
+func TestEvictionPolicy(t *testing.T) {
+	for _, policy := range []EvictionPolicy{LRU, LFU, ARC, TinyLFU} {
+		gee := NewGroup(fmt.Sprintf("policy-%d", policy), 2<<10, GetterFunc(
+			func(key string) ([]byte, error) {
+				return []byte(key), nil
+			}), WithEvictionPolicy(policy))
+		if view, err := gee.Get("Tom"); err != nil || view.String() != "Tom" {
+			t.Fatalf("policy %d: failed to get Tom", policy)
+		}
+		if _, ok := gee.mainCache.get("Tom"); !ok {
+			t.Fatalf("policy %d: Tom should be cached", policy)
+		}
+	}
+}
+
+func TestGroupRegistry(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	g := NewGroup("registry", 2<<10, getter)
+	g.Get("Tom")
+	names := ListGroups()
+	found := false
+	for i, name := range names {
+		found = found || name == "registry"
+		if i > 0 && names[i-1] >= name {
+			t.Fatalf("ListGroups should be sorted: %v", names)
+		}
+	}
+	if !found {
+		t.Fatalf("ListGroups should contain registry: %v", names)
+	}
+
+	//重复注册
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("duplicate NewGroup should panic")
+			}
+		}()
+		NewGroup("registry", 2<<10, getter)
+	}()
+
+	if !DestroyGroup("registry") || GetGroup("registry") != nil {
+		t.Fatalf("DestroyGroup failed")
+	}
+	if cs := g.CacheStats(MainCache); cs.Items != 0 || cs.Bytes != 0 {
+		t.Fatalf("DestroyGroup should free the cache, got %+v", cs)
+	}
+	if DestroyGroup("registry") {
+		t.Fatalf("DestroyGroup of a missing group should return false")
+	}
+	//删除之后可以重新注册
+	NewGroup("registry", 2<<10, getter)
+}
+
+func TestSetCacheBytes(t *testing.T) {
+	for _, policy := range []EvictionPolicy{LRU, LFU, ARC, TinyLFU} {
+		g := NewGroup(fmt.Sprintf("resize-%d", policy), 64<<10, GetterFunc(
+			func(key string) ([]byte, error) {
+				return make([]byte, 100), nil
+			}), WithEvictionPolicy(policy))
+		for i := 0; i < 100; i++ {
+			g.Get(fmt.Sprintf("key%d", i))
+		}
+		if cs := g.CacheStats(MainCache); cs.Items != 100 {
+			t.Fatalf("policy %d: expect 100 items before resize, got %d", policy, cs.Items)
+		}
+		g.SetCacheBytes(2 << 10)
+		if g.CacheBytes() != 2<<10 {
+			t.Fatalf("policy %d: CacheBytes = %d", policy, g.CacheBytes())
+		}
+		cs := g.CacheStats(MainCache)
+		if limit := int64(2<<10 - 2<<10/hotCacheRatio); cs.Bytes > limit || cs.Items == 0 {
+			t.Fatalf("policy %d: after shrinking, %d bytes in %d items, limit %d", policy, cs.Bytes, cs.Items, limit)
+		}
+		if cs.Evictions == 0 {
+			t.Fatalf("policy %d: shrinking should evict", policy)
+		}
+	}
+}
+
+func TestLoadLeaderCancelled(t *testing.T) {
+	started := make(chan struct{}, 1)
+	gee := NewGroup("leader_cancelled", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			select {
+			case <-time.After(50 * time.Millisecond):
+				return []byte(key), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	leader := make(chan error, 1)
+	go func() {
+		_, err := gee.GetContext(ctx, "Tom")
+		leader <- err
+	}()
+	<-started
+	//等待第一个调用方的载入，它超时之后自己重新载入
+	view, err := gee.GetContext(context.Background(), "Tom")
+	if err != nil || view.String() != "Tom" {
+		t.Fatalf("waiter should get the value after the leader is cancelled, got %q, %v", view.String(), err)
+	}
+	if err := <-leader; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("leader should get DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestLoadGetterOwnTimeout(t *testing.T) {
+	var loads int32
+	gee := NewGroup("getter_own_timeout", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			atomic.AddInt32(&loads, 1)
+			//Getter 自己的超时，和调用方的 ctx 无关
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}))
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := gee.Get("Tom")
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expect the getter's DeadlineExceeded, got %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Get should not retry the getter's own timeout")
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n > 2 {
+		t.Fatalf("getter should run at most once per caller, ran %d times", n)
+	}
+}
+
+func TestForwardedLoadsLocally(t *testing.T) {
+	loads := 0
+	gee := NewGroup("forwarded", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	owner := &fakePeer{name: "owner", down: true}
+	gee.RegisterPeers(&fakePicker{owner: owner, replicas: []*fakePeer{owner, nil}})
+	srv := httptest.NewServer(NewHTTPPool(""))
+	defer srv.Close()
+
+	// 其它节点转发来的请求不会再访问拥有者
+	pool := NewHTTPPool("http://self")
+	pool.Set(srv.URL)
+	out := &pb.Response{}
+	if err := pool.getters[srv.URL].Get(context.Background(), &pb.Request{Group: "forwarded", Key: "Tom"}, out); err != nil || string(out.Value) != "Tom" {
+		t.Fatalf("forwarded Get = %q, %v", out.Value, err)
+	}
+	batch := &pb.BatchResponse{}
+	if err := pool.getters[srv.URL].GetMany(context.Background(), &pb.BatchRequest{Group: "forwarded", Keys: []string{"Jack"}}, batch); err != nil {
+		t.Fatal(err)
+	}
+	if owner.gets != 0 || owner.batches != 0 || loads != 2 {
+		t.Fatalf("forwarded requests should load locally, owner gets = %d, batches = %d, loads = %d", owner.gets, owner.batches, loads)
+	}
+
+	// 本机发起的载入仍然先访问拥有者
+	if view, err := gee.Get("Sam"); err != nil || view.String() != "Sam" || owner.gets != 1 {
+		t.Fatalf("local Get should try the owner first, got %q, %v, owner gets = %d", view.String(), err, owner.gets)
+	}
+}
@@ -69,6 +69,7 @@ func (m *Request) GetKey() string {
 
 type Response struct {
 	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Expire               int64    `protobuf:"varint,2,opt,name=expire,proto3" json:"expire,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -106,23 +107,266 @@ func (m *Response) GetValue() []byte {
 	return nil
 }
 
+func (m *Response) GetExpire() int64 {
+	if m != nil {
+		return m.Expire
+	}
+	return 0
+}
+
+type SetRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Expire               int64    `protobuf:"varint,4,opt,name=expire,proto3" json:"expire,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetRequest) Reset()         { *m = SetRequest{} }
+func (m *SetRequest) String() string { return proto.CompactTextString(m) }
+func (*SetRequest) ProtoMessage()    {}
+func (*SetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_889d0a4ad37a0d42, []int{2}
+}
+
+func (m *SetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetRequest.Unmarshal(m, b)
+}
+func (m *SetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetRequest.Marshal(b, m, deterministic)
+}
+func (m *SetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetRequest.Merge(m, src)
+}
+func (m *SetRequest) XXX_Size() int {
+	return xxx_messageInfo_SetRequest.Size(m)
+}
+func (m *SetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetRequest proto.InternalMessageInfo
+
+func (m *SetRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *SetRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *SetRequest) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *SetRequest) GetExpire() int64 {
+	if m != nil {
+		return m.Expire
+	}
+	return 0
+}
+
+type BatchRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Keys                 []string `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchRequest) Reset()         { *m = BatchRequest{} }
+func (m *BatchRequest) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest) ProtoMessage()    {}
+func (*BatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_889d0a4ad37a0d42, []int{3}
+}
+
+func (m *BatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest.Unmarshal(m, b)
+}
+func (m *BatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest.Merge(m, src)
+}
+func (m *BatchRequest) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest.Size(m)
+}
+func (m *BatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest proto.InternalMessageInfo
+
+func (m *BatchRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *BatchRequest) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type BatchResult struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	NotFound             bool     `protobuf:"varint,4,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	Expire               int64    `protobuf:"varint,5,opt,name=expire,proto3" json:"expire,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchResult) Reset()         { *m = BatchResult{} }
+func (m *BatchResult) String() string { return proto.CompactTextString(m) }
+func (*BatchResult) ProtoMessage()    {}
+func (*BatchResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_889d0a4ad37a0d42, []int{4}
+}
+
+func (m *BatchResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchResult.Unmarshal(m, b)
+}
+func (m *BatchResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchResult.Marshal(b, m, deterministic)
+}
+func (m *BatchResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchResult.Merge(m, src)
+}
+func (m *BatchResult) XXX_Size() int {
+	return xxx_messageInfo_BatchResult.Size(m)
+}
+func (m *BatchResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchResult proto.InternalMessageInfo
+
+func (m *BatchResult) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *BatchResult) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *BatchResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *BatchResult) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+func (m *BatchResult) GetExpire() int64 {
+	if m != nil {
+		return m.Expire
+	}
+	return 0
+}
+
+type BatchResponse struct {
+	Results              []*BatchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *BatchResponse) Reset()         { *m = BatchResponse{} }
+func (m *BatchResponse) String() string { return proto.CompactTextString(m) }
+func (*BatchResponse) ProtoMessage()    {}
+func (*BatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_889d0a4ad37a0d42, []int{5}
+}
+
+func (m *BatchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchResponse.Unmarshal(m, b)
+}
+func (m *BatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchResponse.Marshal(b, m, deterministic)
+}
+func (m *BatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchResponse.Merge(m, src)
+}
+func (m *BatchResponse) XXX_Size() int {
+	return xxx_messageInfo_BatchResponse.Size(m)
+}
+func (m *BatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchResponse proto.InternalMessageInfo
+
+func (m *BatchResponse) GetResults() []*BatchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Request)(nil), "geecachepb.Request")
 	proto.RegisterType((*Response)(nil), "geecachepb.Response")
+	proto.RegisterType((*SetRequest)(nil), "geecachepb.SetRequest")
+	proto.RegisterType((*BatchRequest)(nil), "geecachepb.BatchRequest")
+	proto.RegisterType((*BatchResult)(nil), "geecachepb.BatchResult")
+	proto.RegisterType((*BatchResponse)(nil), "geecachepb.BatchResponse")
 }
 
 func init() { proto.RegisterFile("geecachepb.proto", fileDescriptor_889d0a4ad37a0d42) }
 
 var fileDescriptor_889d0a4ad37a0d42 = []byte{
-	// 148 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x48, 0x4f, 0x4d, 0x4d,
-	0x4e, 0x4c, 0xce, 0x48, 0x2d, 0x48, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x42, 0x88,
-	0x28, 0x19, 0x72, 0xb1, 0x07, 0xa5, 0x16, 0x96, 0xa6, 0x16, 0x97, 0x08, 0x89, 0x70, 0xb1, 0xa6,
-	0x17, 0xe5, 0x97, 0x16, 0x48, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x41, 0x38, 0x42, 0x02, 0x5c,
-	0xcc, 0xd9, 0xa9, 0x95, 0x12, 0x4c, 0x60, 0x31, 0x10, 0x53, 0x49, 0x81, 0x8b, 0x23, 0x28, 0xb5,
-	0xb8, 0x20, 0x3f, 0xaf, 0x38, 0x15, 0xa4, 0xa7, 0x2c, 0x31, 0xa7, 0x34, 0x15, 0xac, 0x87, 0x27,
-	0x08, 0xc2, 0x31, 0xb2, 0xe3, 0xe2, 0x72, 0x07, 0x69, 0x76, 0x06, 0x59, 0x22, 0x64, 0xc0, 0xc5,
-	0xec, 0x9e, 0x5a, 0x22, 0x24, 0xac, 0x87, 0xe4, 0x10, 0xa8, 0x9d, 0x52, 0x22, 0xa8, 0x82, 0x10,
-	0x53, 0x93, 0xd8, 0xc0, 0xee, 0x34, 0x06, 0x04, 0x00, 0x00, 0xff, 0xff, 0x5c, 0xd5, 0xdd, 0x09,
-	0xbb, 0x00, 0x00, 0x00,
+	// 338 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0x4f, 0x4f, 0xc2, 0x40,
+	0x10, 0xc5, 0xb3, 0x2c, 0xff, 0x3a, 0x40, 0x24, 0x2b, 0xc1, 0xaa, 0x17, 0xd2, 0x13, 0x27, 0x14,
+	0xb8, 0x90, 0x98, 0x78, 0xc0, 0x44, 0x4e, 0x5e, 0x96, 0x9b, 0x17, 0x53, 0x70, 0x04, 0x03, 0x76,
+	0xeb, 0xee, 0x96, 0xc8, 0xcd, 0x8f, 0xeb, 0xc7, 0x30, 0xdd, 0xb6, 0xe9, 0x36, 0xa9, 0x26, 0xdc,
+	0xf6, 0xcd, 0xcc, 0xcb, 0xfb, 0x75, 0xa6, 0xd0, 0xdd, 0x20, 0xae, 0xfd, 0xf5, 0x16, 0xc3, 0xd5,
+	0x28, 0x94, 0x42, 0x0b, 0x06, 0x79, 0xc5, 0x1b, 0x43, 0x83, 0xe3, 0x67, 0x84, 0x4a, 0xb3, 0x1e,
+	0xd4, 0x36, 0x52, 0x44, 0xa1, 0x4b, 0x06, 0x64, 0xe8, 0xf0, 0x44, 0xb0, 0x2e, 0xd0, 0x1d, 0x1e,
+	0xdd, 0x8a, 0xa9, 0xc5, 0x4f, 0x6f, 0x06, 0x4d, 0x8e, 0x2a, 0x14, 0x81, 0xc2, 0xd8, 0x73, 0xf0,
+	0xf7, 0x11, 0x1a, 0x4f, 0x9b, 0x27, 0x82, 0xf5, 0xa1, 0x8e, 0x5f, 0xe1, 0xbb, 0x44, 0x63, 0xa3,
+	0x3c, 0x55, 0xde, 0x0a, 0x60, 0x89, 0xfa, 0xc4, 0xbc, 0x3c, 0x83, 0x96, 0x67, 0x54, 0x0b, 0x19,
+	0x33, 0x68, 0xcf, 0x7d, 0xbd, 0xde, 0xfe, 0x9f, 0xc2, 0xa0, 0xba, 0xc3, 0xa3, 0x72, 0x2b, 0x03,
+	0x3a, 0x74, 0xb8, 0x79, 0x7b, 0xdf, 0x04, 0x5a, 0xa9, 0x55, 0x45, 0x7b, 0x9d, 0x91, 0x90, 0x12,
+	0x92, 0x8a, 0x4d, 0xd2, 0x83, 0x1a, 0x4a, 0x29, 0xa4, 0xe1, 0x73, 0x78, 0x22, 0xd8, 0x35, 0x38,
+	0x81, 0xd0, 0x2f, 0x6f, 0x22, 0x0a, 0x5e, 0x0d, 0x62, 0x93, 0x37, 0x03, 0xa1, 0x1f, 0x63, 0x6d,
+	0xc1, 0xd7, 0x0a, 0xf0, 0x73, 0xe8, 0x64, 0x04, 0xc9, 0x7e, 0xc7, 0xd0, 0x90, 0x86, 0x46, 0xb9,
+	0x64, 0x40, 0x87, 0xad, 0xc9, 0xc5, 0xc8, 0x3a, 0xa7, 0x45, 0xcb, 0xb3, 0xb9, 0xc9, 0x0f, 0x01,
+	0x58, 0xc4, 0x1f, 0xf9, 0x10, 0x4f, 0xb1, 0x5b, 0xa0, 0x0b, 0xd4, 0xec, 0xdc, 0xf6, 0xa5, 0xbb,
+	0xb9, 0xea, 0x15, 0x8b, 0x69, 0xe6, 0x14, 0xe8, 0x12, 0x35, 0xeb, 0xdb, 0xcd, 0xfc, 0x6c, 0x7f,
+	0x98, 0xee, 0xa1, 0xb1, 0x40, 0xfd, 0xe4, 0x07, 0x47, 0xe6, 0x96, 0x20, 0x26, 0xd6, 0xcb, 0x32,
+	0xf8, 0x2c, 0xb4, 0xce, 0xf1, 0x43, 0x1c, 0xf0, 0x04, 0xd2, 0xf9, 0xd9, 0x73, 0x67, 0x74, 0x73,
+	0x97, 0x77, 0x56, 0x75, 0xf3, 0x83, 0x4f, 0x7f, 0x07, 0x00, 0x56, 0xb0, 0xdb, 0xef, 0xf4, 0x02,
+	0x00, 0x00,
 }
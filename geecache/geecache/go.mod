@@ -1,4 +1,9 @@
 module geecache
 
 go 1.18
-require google.golang.org/protobuf v1.31.0 // indirect
\ No newline at end of file
+
+require geerpc v0.0.0
+
+require google.golang.org/protobuf v1.31.0 // indirect
+
+replace geerpc => ../../geerpc
\ No newline at end of file
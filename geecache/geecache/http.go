@@ -4,47 +4,72 @@
 package geecache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"geecache/consistenthash"
 	pb "geecache/geecachepb"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 )
 
 //节点之间通信地址的前缀，具有这个前缀的请求用于节点之间的访问
 const defaultBasePath = "/_geecache/"
-const defaultReplicas = 50
+
+//统计数据的访问路径，即 /_geecache/_stats
+const statsPath = "_stats"
+
+//请求头，剩余的超时时间，例如 1.5s，远程节点据此设置自己的截止时间，
+//传递相对时间可以避免节点之间的时钟误差
+const timeoutHeader = "X-Geecache-Timeout"
+
+//响应头，表示数据源中没有这个 key，用来区分 404 是因为没有 key 还是没有 group
+const notFoundHeader = "X-Geecache-Not-Found"
+
+//请求头，表示请求是其它节点在载入时转发来的，收到的节点只在本机载入，不再转发
+const forwardedHeader = "X-Geecache-Forwarded"
 
 //用来承载节点之间的HTTP通信的核心数据结构
 type HTTPPool struct {
-	//用来记录自己的地址
-	self string
+	//一致性哈希环，以及每个节点对应的httpGetter，每一个httpGetter对应一个远程节点
+	peerRing
 	// 作为节点间通讯地址的前缀，默认是 /_geecache/，那么 http://example.com/_geecache/ 开头的请求，
 	// 就用于节点间的访问。为了避免与用户的请求冲突，约定访问节点间通讯地址的前缀默认添加 /_geecache/ 前缀
 	basePath string
-	mu       sync.Mutex
-	//节点间通讯地址的map，key是具体的节点的地址，value是对应节点的httpGetter，每一个httpGetter对应一个远程节点
-	httpGetters map[string]*httpGetter
-	//根据具体的key选择节点的一致性哈希算法的实例
-	peers *consistenthash.Map
+	//访问其它节点时使用的客户端，由 HTTPPoolOption 配置
+	client *http.Client
+	//创建 client 的配置
+	clientOpts httpClientOptions
+	//签名的密钥和时间窗口，由 WithSecret 和 WithReplayWindow 配置
+	secret       []byte
+	replayWindow time.Duration
+	//请求签名，nil 表示不签名也不校验
+	signer *signer
 }
 
-func NewHTTPPool(self string) *HTTPPool {
-	return &HTTPPool{
-		self:     self,
+// 节点地址可以是 https://，此时需要用 WithTLSConfig 设置证书
+func NewHTTPPool(self string, opts ...HTTPPoolOption) *HTTPPool {
+	p := &HTTPPool{
 		basePath: defaultBasePath,
 	}
-}
-
-// Log info with server name
-func (p *HTTPPool) Log(format string, v ...interface{}) {
-	log.Printf("[Server %s] %s", p.self, fmt.Sprintf(format, v...))
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.client = p.clientOpts.build()
+	p.signer = newSigner(p.secret, p.replayWindow)
+	p.peerRing = peerRing{
+		self: self,
+		newGetter: func(peer string) PeerGetter {
+			return &httpGetter{peer: peer, baseURL: peer + p.basePath, client: p.client, signer: p.signer}
+		},
+	}
+	return p
 }
 
 //约定访问路径格式为 /<basepath>/<groupname>/<key>
@@ -55,6 +80,10 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	//记录日志
 	p.Log("%s %s", r.Method, r.URL.Path)
+	if r.URL.Path[len(p.basePath):] == statsPath {
+		p.serveStats(w, r)
+		return
+	}
 	//使用/分割url，只要分割出来3部分，就停止，从groupName开始分割，前面通过切片跳过了
 	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
 	if len(parts) != 2 {
@@ -64,59 +93,208 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	//获取group和key
 	groupName := parts[0]
 	key := parts[1]
+	//开启签名时先校验请求，请求体读出来之后放回去，后面仍然可以读取
+	if p.signer != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err = p.signer.verify(r, groupName, key, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
 	//获取当前节点下的groupName对应的group
 	group := GetGroup(groupName)
 	if group == nil {
 		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
 		return
 	}
+	//DELETE 请求只删除本节点的缓存，不再转发给其它节点
+	if r.Method == http.MethodDelete {
+		group.removeLocally(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	//PUT 请求把请求体中的值写入本节点的缓存
+	if r.Method == http.MethodPut {
+		body, err := readBody(r.Header, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		in := &pb.SetRequest{}
+		if err = proto.Unmarshal(body, in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		group.populateCache(key, ByteView{b: in.GetValue()}, unixNanoTime(in.GetExpire()))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	//使用请求方传来的截止时间，客户端断开时 r.Context() 也会结束
+	ctx := r.Context()
+	if v := r.Header.Get(timeoutHeader); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "bad "+timeoutHeader+": "+v, http.StatusBadRequest)
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if r.Header.Get(forwardedHeader) != "" {
+		ctx = withForwarded(ctx)
+	}
+	//POST 请求一次获取请求体中的多个 key
+	if r.Method == http.MethodPost {
+		p.serveBatch(ctx, w, r, group)
+		return
+	}
+	group.Stats.ServerRequests.Add(1)
 	//获取需要的key的缓存值，如果没有就返回error
-	view, err := group.Get(key)
+	view, err := group.GetContext(ctx, key)
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, ErrNotFound) {
+		w.Header().Set(notFoundHeader, "1")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Write the value to the response body as a proto message.
-	body, err := proto.Marshal(&pb.Response{Value: view.ByteSlice()})
+	body, err := proto.Marshal(&pb.Response{Value: view.ByteSlice(), Expire: unixNano(view.expire)})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	//将缓存值写入到ResponseWriter
-	w.Header().Set("Content-Type", "application/octet-stream")
+	//将缓存值写入到ResponseWriter，请求方支持时压缩
+	writeBody(w, r, group, body)
+}
+
+//请求体是 BatchRequest，返回 BatchResponse，每个 key 的错误放在各自的结果中
+func (p *HTTPPool) serveBatch(ctx context.Context, w http.ResponseWriter, r *http.Request, group *Group) {
+	body, err := readBody(r.Header, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in := &pb.BatchRequest{}
+	if err = proto.Unmarshal(body, in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group.Stats.ServerRequests.Add(int64(len(in.GetKeys())))
+	out := &pb.BatchResponse{}
+	batchResponse(group.GetManyContext(ctx, in.GetKeys()), out)
+	body, err = proto.Marshal(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, r, group, body)
+}
+
+//一个Group对外展示的统计数据
+type groupStats struct {
+	Stats     *Stats     `json:"stats"`
+	MainCache CacheStats `json:"main_cache"`
+	HotCache  CacheStats `json:"hot_cache"`
+}
+
+//以JSON格式返回所有Group的统计数据，可以用 ?group=<name> 只返回一个Group
+func (p *HTTPPool) serveStats(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("group")
+	result := make(map[string]groupStats)
+	mu.RLock()
+	for _, g := range groups {
+		if name != "" && g.name != name {
+			continue
+		}
+		result[g.name] = groupStats{
+			Stats:     &g.Stats,
+			MainCache: g.CacheStats(MainCache),
+			HotCache:  g.CacheStats(HotCache),
+		}
+	}
+	mu.RUnlock()
+	if name != "" && len(result) == 0 {
+		http.Error(w, "no such group: "+name, http.StatusNotFound)
+		return
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(body)
 }
 
 type httpGetter struct {
+	//远程节点的地址，用于统计
+	peer string
 	//用来访问远程节点的地址，http://example.com/_geecache/
 	baseURL string
+	//发送请求的客户端，nil 表示使用 http.DefaultClient
+	client *http.Client
+	//请求签名，nil 表示不签名
+	signer *signer
+}
+
+func (h *httpGetter) httpClient() *http.Client {
+	if h.client == nil {
+		return http.DefaultClient
+	}
+	return h.client
+}
+
+//开启签名时为请求签名
+func (h *httpGetter) sign(req *http.Request, group, key string, body []byte) {
+	if h.signer != nil {
+		h.signer.sign(req, group, key, body)
+	}
 }
 
 //实现了PeerGetter接口的Get方法，用来访问远程节点
 // func (h *httpGetter) Get(group string, key string) ([]byte, error) {
-func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
-	//拼接访问远程节点的URL
-	u := fmt.Sprintf(
-		"%v%v/%v",
-		h.baseURL,
-		//转义字符串，以便可以放置在URL查询中
-		url.QueryEscape(in.GetGroup()),
-		url.QueryEscape(in.GetKey()),
-	)
+func (h *httpGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) (err error) {
+	defer func() {
+		recordPeerRequest(in.GetGroup(), h.peer, "Get", err)
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url(in), nil)
+	if err != nil {
+		return err
+	}
+	h.sign(req, in.GetGroup(), in.GetKey(), nil)
+	setTimeout(ctx, req)
+	req.Header.Set(forwardedHeader, "1")
+	req.Header.Set("Accept-Encoding", acceptEncoding())
 	//发起http请求
-	res, err := http.Get(u)
+	res, err := h.httpClient().Do(req)
 	if err != nil {
 		return err
 	}
 	//关闭请求
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound && res.Header.Get(notFoundHeader) != "" {
+		return ErrNotFound
+	}
 	if res.StatusCode != http.StatusOK {
 		return fmt.Errorf("server returned: %v", res.Status)
 	}
-	//读取结果
-	bytes, err := ioutil.ReadAll(res.Body)
+	//读取结果，按 Content-Encoding 解压
+	bytes, err := readBody(res.Header, res.Body)
 	if err != nil {
 		return fmt.Errorf("reading response body: %v", err)
 	}
@@ -129,32 +307,110 @@ func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
 
 }
 
-func (p *HTTPPool) Set(peers ...string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	//初始化一致性哈希算法
-	p.peers = consistenthash.New(defaultReplicas, nil)
-	//添加传入的节点
-	p.peers.Add(peers...)
-	//初始化httpGetters
-	p.httpGetters = make(map[string]*httpGetter, len(peers))
-	//为每一个节点创建一个httpGetter
-	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath}
+//实现了PeerGetter接口的GetMany方法，把多个 key 放在一个 POST 请求中
+func (h *httpGetter) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) (err error) {
+	defer func() {
+		recordPeerRequest(in.GetGroup(), h.peer, "GetMany", err)
+	}()
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encoding request body: %v", err)
+	}
+	u := h.baseURL + url.PathEscape(in.GetGroup()) + "/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	h.sign(req, in.GetGroup(), "", body)
+	setTimeout(ctx, req)
+	req.Header.Set(forwardedHeader, "1")
+	req.Header.Set("Accept-Encoding", acceptEncoding())
+	res, err := h.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	body, err = readBody(res.Header, res.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if err = proto.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
+
+//把 ctx 剩余的时间告诉远程节点
+func setTimeout(ctx context.Context, req *http.Request) {
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(timeoutHeader, time.Until(deadline).String())
 	}
 }
 
-//根据具体的key选择节点，返回对应的httpGetter
-func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	//根据具体的key选择节点
-	if peer := p.peers.Get(key); peer != "" && peer != p.self {
-		p.Log("Pick peer %s", peer)
-		//返回对应的httpGetter
-		return p.httpGetters[peer], true
-	}
-	return nil, false
+//实现了PeerGetter接口的Remove方法，删除远程节点上的缓存
+func (h *httpGetter) Remove(in *pb.Request) (err error) {
+	defer func() {
+		recordPeerRequest(in.GetGroup(), h.peer, "Remove", err)
+	}()
+	req, err := http.NewRequest(http.MethodDelete, h.url(in), nil)
+	if err != nil {
+		return err
+	}
+	h.sign(req, in.GetGroup(), in.GetKey(), nil)
+	res, err := h.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
+//实现了PeerGetter接口的Set方法，把值写入远程节点的缓存
+func (h *httpGetter) Set(in *pb.SetRequest) (err error) {
+	defer func() {
+		recordPeerRequest(in.GetGroup(), h.peer, "Set", err)
+	}()
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encoding request body: %v", err)
+	}
+	//大的值压缩之后再发送
+	body, encoding := compressRequest(in.GetGroup(), body)
+	u := h.url(&pb.Request{Group: in.GetGroup(), Key: in.GetKey()})
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	h.sign(req, in.GetGroup(), in.GetKey(), body)
+	res, err := h.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
+//拼接访问远程节点的URL
+func (h *httpGetter) url(in *pb.Request) string {
+	return fmt.Sprintf(
+		"%v%v/%v",
+		h.baseURL,
+		//转义字符串，以便可以放置在URL查询中
+		url.PathEscape(in.GetGroup()),
+		url.PathEscape(in.GetKey()),
+	)
 }
 
 var _ PeerPicker = (*HTTPPool)(nil)
@@ -0,0 +1,371 @@
+package geecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geecache/consistenthash"
+	pb "geecache/geecachepb"
+	"geerpc/registry"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHTTPRemove(t *testing.T) {
+	loads := 0
+	gee := NewGroup("http_remove", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	pool := NewHTTPPool("http://self")
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+
+	if _, err := gee.Get("Tom"); err != nil || loads != 1 {
+		t.Fatalf("failed to load Tom")
+	}
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	if err := getter.Remove(&pb.Request{Group: "http_remove", Key: "Tom"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gee.Get("Tom"); err != nil || loads != 2 {
+		t.Fatalf("removed Tom should be loaded again, loads = %d", loads)
+	}
+
+	res, err := http.Get(srv.URL + defaultBasePath + "http_remove/Tom")
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("GET after remove failed: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestHTTPSet(t *testing.T) {
+	gee := NewGroup("http_set", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist", key)
+		}))
+	srv := httptest.NewServer(NewHTTPPool("http://self"))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	in := &pb.SetRequest{Group: "http_set", Key: "Tom", Value: []byte("630")}
+	if err := getter.Set(in); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := gee.Get("Tom"); err != nil || view.String() != "630" {
+		t.Fatalf("Set Tom through HTTP failed: %v", err)
+	}
+
+	// 已经过期的值不会被命中
+	in = &pb.SetRequest{Group: "http_set", Key: "Sam", Value: []byte("567"),
+		Expire: time.Now().Add(-time.Second).UnixNano()}
+	if err := getter.Set(in); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gee.Get("Sam"); err == nil {
+		t.Fatalf("expired Sam should be a miss")
+	}
+}
+
+func TestHTTPDeadline(t *testing.T) {
+	deadlines := make(chan bool, 1)
+	NewGroup("http_deadline", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			_, ok := ctx.Deadline()
+			deadlines <- ok
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}))
+	srv := httptest.NewServer(NewHTTPPool("http://self"))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := getter.Get(ctx, &pb.Request{Group: "http_deadline", Key: "Tom"}, &pb.Response{})
+	if err == nil {
+		t.Fatalf("expect timeout error")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Get should return after the deadline, took %v", d)
+	}
+	// 截止时间通过请求头传递给了远程节点上的 Getter
+	if !<-deadlines {
+		t.Fatalf("remote Getter should receive a context with deadline")
+	}
+}
+
+func TestHTTPNotFound(t *testing.T) {
+	NewGroup("http_not_found", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}))
+	srv := httptest.NewServer(NewHTTPPool("http://self"))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	err := getter.Get(context.Background(), &pb.Request{Group: "http_not_found", Key: "Tom"}, &pb.Response{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect ErrNotFound, got %v", err)
+	}
+	// 没有 group 不是 ErrNotFound
+	err = getter.Get(context.Background(), &pb.Request{Group: "unknown", Key: "Tom"}, &pb.Response{})
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing group should not be ErrNotFound, got %v", err)
+	}
+}
+
+func TestHTTPGetMany(t *testing.T) {
+	NewGroup("http_get_many", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "Unknown" {
+				return nil, ErrNotFound
+			}
+			if key == "Broken" {
+				return nil, fmt.Errorf("db error")
+			}
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("http://self"))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	in := &pb.BatchRequest{Group: "http_get_many", Keys: []string{"Tom", "Unknown", "Broken"}}
+	out := &pb.BatchResponse{}
+	if err := getter.GetMany(context.Background(), in, out); err != nil {
+		t.Fatal(err)
+	}
+	res := out.GetResults()
+	if len(res) != 3 {
+		t.Fatalf("expect 3 results, got %d", len(res))
+	}
+	if res[0].GetKey() != "Tom" || string(res[0].GetValue()) != "Tom" {
+		t.Fatalf("unexpected result for Tom: %v", res[0])
+	}
+	if !res[1].GetNotFound() {
+		t.Fatalf("Unknown should be not found: %v", res[1])
+	}
+	if res[2].GetError() != "db error" {
+		t.Fatalf("Broken should carry its error: %v", res[2])
+	}
+}
+
+func TestHTTPExpire(t *testing.T) {
+	gee := NewGroup("http_expire", 2<<10, TTLGetterFunc(
+		func(key string) ([]byte, time.Duration, error) {
+			return []byte(key), time.Hour, nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool(""))
+	defer srv.Close()
+	pool := NewHTTPPool("http://self")
+	pool.Set(srv.URL)
+	getter := pool.getters[srv.URL]
+
+	// 拥有者的过期时间随响应一起返回
+	out := &pb.Response{}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "http_expire", Key: "Tom"}, out); err != nil {
+		t.Fatal(err)
+	}
+	_, _, expire, _ := gee.mainCache.peek("Tom")
+	if out.Expire != expire.UnixNano() {
+		t.Fatalf("Get expire = %d, want %d", out.Expire, expire.UnixNano())
+	}
+	batch := &pb.BatchResponse{}
+	if err := getter.GetMany(context.Background(), &pb.BatchRequest{Group: "http_expire", Keys: []string{"Tom"}}, batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Results) != 1 || batch.Results[0].Expire != expire.UnixNano() {
+		t.Fatalf("GetMany expire = %v, want %d", batch.Results, expire.UnixNano())
+	}
+
+	// 热点缓存使用拥有者的过期时间，而不是 Group 的默认值
+	for i := 0; i < 1000; i++ {
+		if _, _, hot, ok := gee.hotCache.peek("Tom"); ok {
+			if !hot.Equal(expire) {
+				t.Fatalf("hot cache expire = %v, want %v", hot, expire)
+			}
+			return
+		}
+		if _, err := gee.getFromPeer(context.Background(), getter, "Tom"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Fatal("Tom never sampled into hot cache")
+}
+
+func TestHTTPStats(t *testing.T) {
+	gee := NewGroup("http_stats", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool("http://self"))
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := gee.Get("Tom"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := http.Get(srv.URL + defaultBasePath + statsPath + "?group=http_stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var result map[string]struct {
+		Stats     map[string]int64 `json:"stats"`
+		MainCache CacheStats       `json:"main_cache"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	s := result["http_stats"]
+	if s.Stats["gets"] != 3 || s.Stats["cache_hits"] != 2 || s.Stats["local_loads"] != 1 {
+		t.Fatalf("unexpected stats %v", s.Stats)
+	}
+	if s.MainCache.Items != 1 || s.MainCache.Bytes != int64(len("Tom")*2) {
+		t.Fatalf("unexpected main cache stats %+v", s.MainCache)
+	}
+}
+
+func TestHTTPPoolMembership(t *testing.T) {
+	pool := NewHTTPPool("http://self")
+	pool.Set("http://self", "http://a")
+	a, _ := pool.getters["http://a"].(*httpGetter)
+
+	pool.AddPeers("http://a", "http://b")
+	if len(pool.GetAll()) != 2 {
+		t.Fatalf("expect 2 peers after AddPeers, got %d", len(pool.GetAll()))
+	}
+	if pool.getters["http://a"] != a {
+		t.Fatalf("AddPeers should keep the existing httpGetter of http://a")
+	}
+
+	pool.RemovePeers("http://a")
+	for i := 0; i < 100; i++ {
+		if peer, ok := pool.PickPeer(strconv.Itoa(i)); ok && peer == a {
+			t.Fatalf("removed peer http://a should not be picked")
+		}
+	}
+	if len(pool.GetAll()) != 1 {
+		t.Fatalf("expect 1 peer after RemovePeers, got %d", len(pool.GetAll()))
+	}
+}
+
+func TestHTTPPoolWeights(t *testing.T) {
+	pool := NewHTTPPool("http://self")
+	pool.SetWeighted(map[string]int{"http://self": 1, "http://big": 3})
+	pool.AddWeightedPeer("http://small", 1)
+
+	counts := make(map[PeerGetter]int)
+	const n = 10000
+	for i := 0; i < n; i++ {
+		if peer, ok := pool.PickPeer(strconv.Itoa(i)); ok {
+			counts[peer]++
+		}
+	}
+	big, small := counts[pool.getters["http://big"]], counts[pool.getters["http://small"]]
+	// 权重为 3 的节点大约分到 3/5 的 key，权重为 1 的大约 1/5
+	if big < n/2 || small > n/3 {
+		t.Fatalf("keys not proportional to weights: big=%d small=%d", big, small)
+	}
+}
+
+func TestHTTPPoolPicker(t *testing.T) {
+	pool := NewHTTPPool("http://self")
+	pool.Set("http://self", "http://a", "http://b")
+	pool.SetPicker(func() consistenthash.Picker { return consistenthash.NewRendezvous() })
+
+	// 更换算法之后节点保持不变，选择结果和单独使用 Rendezvous 一致
+	hrw := consistenthash.NewRendezvous()
+	hrw.Add("http://self", "http://a", "http://b")
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		peer, ok := pool.PickPeer(key)
+		owner := hrw.Get(key)
+		if ok != (owner != "http://self") || (ok && peer != pool.getters[owner]) {
+			t.Fatalf("key %s should be picked on %s", key, owner)
+		}
+	}
+
+	// Bounded 需要在请求期间记录节点的负载
+	pool.SetPicker(func() consistenthash.Picker { return consistenthash.NewBounded(50, nil, 0.25) })
+	for i := 0; i < 100; i++ {
+		if peer, ok := pool.PickPeer(strconv.Itoa(i)); ok {
+			if _, ok := peer.(*loadGetter); !ok {
+				t.Fatalf("expect loadGetter with bounded picker, got %T", peer)
+			}
+			// 同一个节点每次返回相同的 PeerGetter，GetAll 中也是同一个
+			if again := pool.PickPeers(strconv.Itoa(i), 1); again[0] != peer {
+				t.Fatalf("picks of the same peer should be equal")
+			}
+			found := false
+			for _, getter := range pool.GetAll() {
+				found = found || getter == peer
+			}
+			if !found {
+				t.Fatalf("GetAll should contain the picked getter")
+			}
+			return
+		}
+	}
+	t.Fatalf("no key picked on remote peers")
+}
+
+func TestHTTPPoolPickPeers(t *testing.T) {
+	pool := NewHTTPPool("http://self")
+	pool.Set("http://self", "http://a", "http://b")
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		peers := pool.PickPeers(key, 3)
+		if len(peers) != 3 {
+			t.Fatalf("expect 3 replicas, got %d", len(peers))
+		}
+		// 第一个是拥有者，自己对应的位置为 nil
+		owner, ok := pool.PickPeer(key)
+		if (ok && peers[0] != owner) || (!ok && peers[0] != nil) {
+			t.Fatalf("first replica of %s should be the owner", key)
+		}
+		self := 0
+		for _, peer := range peers {
+			if peer == nil {
+				self++
+			}
+		}
+		if self != 1 {
+			t.Fatalf("expect self once in replicas of %s, got %d", key, self)
+		}
+	}
+}
+
+func TestHTTPPoolWatch(t *testing.T) {
+	reg := httptest.NewServer(registry.New(100 * time.Millisecond))
+	defer reg.Close()
+
+	stopA := NewHTTPPool("http://a").Heartbeat(reg.URL, 20*time.Millisecond)
+	defer stopA()
+	stopB := NewHTTPPool("http://b").Heartbeat(reg.URL, 20*time.Millisecond)
+
+	pool := NewHTTPPool("http://self")
+	stop := pool.Watch(reg.URL, 20*time.Millisecond)
+	defer stop()
+	if n := len(pool.GetAll()); n != 2 {
+		t.Fatalf("expect 2 peers from registry, got %d", n)
+	}
+
+	// b 停止心跳，超时之后从哈希环中删除
+	stopB()
+	time.Sleep(250 * time.Millisecond)
+	pool.mu.Lock()
+	_, okA := pool.getters["http://a"]
+	_, okB := pool.getters["http://b"]
+	pool.mu.Unlock()
+	if !okA || okB {
+		t.Fatalf("expect only http://a alive, got a=%v b=%v", okA, okB)
+	}
+}
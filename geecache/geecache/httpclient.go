@@ -0,0 +1,95 @@
+/*
+配置访问其它节点的 http.Client：超时、连接池和 TLS
+*/
+package geecache
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// HTTPPoolOption 用来在 NewHTTPPool 时修改访问其它节点的方式
+type HTTPPoolOption func(*HTTPPool)
+
+//创建 http.Client 需要的配置，零值表示使用 http.DefaultTransport 的默认值
+type httpClientOptions struct {
+	client              *http.Client
+	transport           http.RoundTripper
+	timeout             time.Duration
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	tlsConfig           *tls.Config
+}
+
+// 使用自己的 http.Client，其它选项中只有 WithRequestTimeout 仍然生效
+func WithHTTPClient(client *http.Client) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.clientOpts.client = client
+	}
+}
+
+// 使用自己的 RoundTripper，连接池和 TLS 的选项不再生效
+func WithTransport(rt http.RoundTripper) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.clientOpts.transport = rt
+	}
+}
+
+// 设置每个请求的超时时间，包括连接、发送和读取响应，0 表示不限制，
+// 请求的 ctx 有更早的截止时间时以 ctx 为准
+func WithRequestTimeout(timeout time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.clientOpts.timeout = timeout
+	}
+}
+
+// 设置空闲连接池：总的空闲连接数、每个节点的空闲连接数和空闲连接的保留时间，
+// 节点之间的请求很频繁，每个节点的空闲连接数应该比默认的 2 大
+func WithIdleConns(maxIdle, maxIdlePerHost int, idleTimeout time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.clientOpts.maxIdleConns = maxIdle
+		p.clientOpts.maxIdleConnsPerHost = maxIdlePerHost
+		p.clientOpts.idleConnTimeout = idleTimeout
+	}
+}
+
+// 设置访问 https:// 节点时使用的 TLS 配置，RootCAs 用来验证其它节点的证书，
+// 设置 Certificates 时会向其它节点出示客户端证书，用于双向 TLS
+func WithTLSConfig(config *tls.Config) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.clientOpts.tlsConfig = config
+	}
+}
+
+//根据配置创建 http.Client
+func (o httpClientOptions) build() *http.Client {
+	if o.client != nil {
+		if o.timeout == 0 {
+			return o.client
+		}
+		//不修改调用方的 client
+		c := *o.client
+		c.Timeout = o.timeout
+		return &c
+	}
+	rt := o.transport
+	if rt == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if o.maxIdleConns > 0 {
+			t.MaxIdleConns = o.maxIdleConns
+		}
+		if o.maxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = o.maxIdleConnsPerHost
+		}
+		if o.idleConnTimeout > 0 {
+			t.IdleConnTimeout = o.idleConnTimeout
+		}
+		if o.tlsConfig != nil {
+			t.TLSClientConfig = o.tlsConfig
+		}
+		rt = t
+	}
+	return &http.Client{Transport: rt, Timeout: o.timeout}
+}
@@ -0,0 +1,158 @@
+package geecache
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	pb "geecache/geecachepb"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func init() {
+	NewGroup("http_tls", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+}
+
+// 从 pool 中取出 peer 对应的 httpGetter，读取 http_tls 中的 key
+func getTLS(pool *HTTPPool, peer, key string) (string, error) {
+	pool.Set(peer)
+	out := &pb.Response{}
+	err := pool.getters[peer].Get(context.Background(), &pb.Request{Group: "http_tls", Key: key}, out)
+	return string(out.GetValue()), err
+}
+
+// 信任 srv 证书的 RootCAs
+func serverRoots(srv *httptest.Server) *x509.CertPool {
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	return roots
+}
+
+func TestHTTPPoolTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(NewHTTPPool(""))
+	defer srv.Close()
+
+	// 不信任服务端的证书
+	if _, err := getTLS(NewHTTPPool("http://self"), srv.URL, "Tom"); err == nil {
+		t.Fatalf("expect certificate error without RootCAs")
+	}
+
+	pool := NewHTTPPool("http://self", WithTLSConfig(&tls.Config{RootCAs: serverRoots(srv)}))
+	if v, err := getTLS(pool, srv.URL, "Tom"); err != nil || v != "Tom" {
+		t.Fatalf("Get over TLS = %q, %v", v, err)
+	}
+}
+
+// 生成一个自签名的客户端证书
+func clientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "geecache-peer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestHTTPPoolMutualTLS(t *testing.T) {
+	clientCert, caCert := clientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+
+	srv := httptest.NewUnstartedServer(NewHTTPPool(""))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// 没有客户端证书时握手失败
+	pool := NewHTTPPool("http://self", WithTLSConfig(&tls.Config{RootCAs: serverRoots(srv)}))
+	if _, err := getTLS(pool, srv.URL, "Tom"); err == nil {
+		t.Fatalf("expect handshake error without client certificate")
+	}
+
+	pool = NewHTTPPool("http://self", WithTLSConfig(&tls.Config{
+		RootCAs:      serverRoots(srv),
+		Certificates: []tls.Certificate{clientCert},
+	}))
+	if v, err := getTLS(pool, srv.URL, "Tom"); err != nil || v != "Tom" {
+		t.Fatalf("Get over mutual TLS = %q, %v", v, err)
+	}
+}
+
+func TestHTTPPoolRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	pool := NewHTTPPool("http://self", WithRequestTimeout(50*time.Millisecond))
+	pool.Set(srv.URL)
+	start := time.Now()
+	err := pool.getters[srv.URL].Get(context.Background(), &pb.Request{Group: "http_tls", Key: "Tom"}, &pb.Response{})
+	if err == nil {
+		t.Fatalf("expect timeout error")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("request should time out after 50ms, took %v", d)
+	}
+}
+
+// 记录请求个数的 RoundTripper
+type countingTransport struct {
+	n int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.n++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPPoolTransport(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPPool(""))
+	defer srv.Close()
+
+	rt := &countingTransport{}
+	pool := NewHTTPPool("http://self", WithTransport(rt))
+	if v, err := getTLS(pool, srv.URL, "Tom"); err != nil || v != "Tom" {
+		t.Fatalf("Get with custom transport = %q, %v", v, err)
+	}
+	if rt.n != 1 {
+		t.Fatalf("custom transport should be used, got %d requests", rt.n)
+	}
+
+	// 连接池的选项作用在默认的 Transport 上
+	pool = NewHTTPPool("http://self", WithIdleConns(100, 32, time.Minute))
+	tr := pool.client.Transport.(*http.Transport)
+	if tr.MaxIdleConns != 100 || tr.MaxIdleConnsPerHost != 32 || tr.IdleConnTimeout != time.Minute {
+		t.Fatalf("idle connection options not applied: %d %d %v", tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+}
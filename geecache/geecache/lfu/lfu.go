@@ -0,0 +1,227 @@
+package lfu
+
+import (
+	"container/list"
+	"geecache/lru"
+	"sort"
+	"time"
+)
+
+// Cache 是按访问频率淘汰的缓存，访问次数最少的条目最先被淘汰，
+// 访问次数相同时淘汰最久没有访问的条目，接口与 lru.Cache 相同
+type Cache struct {
+	//cache允许最大内存
+	maxBytes int64
+	//cache当前已使用的内存
+	nbytes int64
+	//字典，值是所在频率链表中的节点
+	cache map[string]*list.Element
+	//访问频率 -> 该频率下所有条目组成的双向链表，队首是最近访问的
+	freqs map[int]*list.List
+	//当前最小的访问频率，可能已经过时，淘汰时会重新计算
+	minFreq int
+	// 可选，当条目被清除时执行
+	OnEvicted func(key string, value lru.Value)
+}
+
+// 双向链表节点的数据类型
+type entry struct {
+	key   string
+	value lru.Value
+	// 过期时间，零值表示永不过期
+	expire time.Time
+	// 访问次数
+	freq int
+}
+
+// 判断条目在 now 时刻是否已经过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && !now.Before(e.expire)
+}
+
+func New(maxBytes int64, onEvicted func(string, lru.Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		cache:     make(map[string]*list.Element),
+		freqs:     make(map[int]*list.List),
+		OnEvicted: onEvicted,
+	}
+}
+
+// 查找功能，命中后访问次数加一，已经过期的条目视为未命中，并顺便删除
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if kv.expired(time.Now()) {
+			c.removeElement(ele)
+			return nil, false
+		}
+		c.increment(ele)
+		return kv.value, true
+	}
+	return
+}
+
+// 查找但不增加访问次数，已经过期的条目视为不存在，但不会删除
+func (c *Cache) Peek(key string) (value lru.Value, expire time.Time, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if !kv.expired(time.Now()) {
+			return kv.value, kv.expire, true
+		}
+	}
+	return
+}
+
+// 添加，永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// 添加，并指定过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	if ele, ok := c.cache[key]; ok {
+		//键存在则更新值，并算作一次访问
+		kv := ele.Value.(*entry)
+		c.nbytes += int64(value.Len()) - int64(kv.value.Len())
+		kv.value = value
+		kv.expire = expire
+		c.increment(ele)
+	} else {
+		//先淘汰再添加，否则已有条目的访问次数都大于 1 时，新条目会被立即淘汰
+		size := int64(len(key)) + int64(value.Len())
+		for c.maxBytes != 0 && c.maxBytes < c.nbytes+size && len(c.cache) > 0 {
+			c.RemoveOldest()
+		}
+		//新条目的访问次数为 1
+		c.cache[key] = c.list(1).PushFront(&entry{key: key, value: value, expire: expire, freq: 1})
+		c.minFreq = 1
+		c.nbytes += size
+	}
+	//如果超过了设定的最大内存，则移除访问次数最少的节点
+	for c.maxBytes != 0 && c.maxBytes < c.nbytes {
+		c.RemoveOldest()
+	}
+}
+
+// 淘汰访问次数最少的条目中最久没有访问的那个
+func (c *Cache) RemoveOldest() {
+	if len(c.cache) == 0 {
+		return
+	}
+	l, ok := c.freqs[c.minFreq]
+	if !ok {
+		//minFreq 对应的链表已经被删空，重新找最小的频率
+		c.minFreq = 0
+		for freq := range c.freqs {
+			if c.minFreq == 0 || freq < c.minFreq {
+				c.minFreq = freq
+			}
+		}
+		l = c.freqs[c.minFreq]
+	}
+	c.removeElement(l.Back())
+}
+
+// 删除指定的条目，返回条目是否存在
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+		return true
+	}
+	return false
+}
+
+// 删除所有已经过期的条目，返回删除的个数
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for _, ele := range c.cache {
+		if ele.Value.(*entry).expired(now) {
+			c.removeElement(ele)
+			n++
+		}
+	}
+	return n
+}
+
+// 修改最大内存，变小时立即淘汰访问次数最少的条目，0 表示不限制
+func (c *Cache) SetMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+	for c.maxBytes != 0 && c.maxBytes < c.nbytes {
+		c.RemoveOldest()
+	}
+}
+
+// 从旧到新遍历所有未过期的条目，访问次数少的在前，次数相同时最久没有访问的在前，
+// 不改变访问次数，fn 返回 false 时停止
+func (c *Cache) Range(fn func(key string, value lru.Value, expire time.Time) bool) {
+	freqs := make([]int, 0, len(c.freqs))
+	for freq := range c.freqs {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+	now := time.Now()
+	for _, freq := range freqs {
+		for ele := c.freqs[freq].Back(); ele != nil; ele = ele.Prev() {
+			kv := ele.Value.(*entry)
+			if kv.expired(now) {
+				continue
+			}
+			if !fn(kv.key, kv.value, kv.expire) {
+				return
+			}
+		}
+	}
+}
+
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// 返回当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
+// 返回指定频率的链表，不存在则创建
+func (c *Cache) list(freq int) *list.List {
+	l, ok := c.freqs[freq]
+	if !ok {
+		l = list.New()
+		c.freqs[freq] = l
+	}
+	return l
+}
+
+// 访问次数加一，把节点移动到下一个频率的链表
+func (c *Cache) increment(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	c.unlink(ele)
+	if c.minFreq == kv.freq && c.freqs[kv.freq] == nil {
+		c.minFreq++
+	}
+	kv.freq++
+	c.cache[kv.key] = c.list(kv.freq).PushFront(kv)
+}
+
+// 从频率链表中摘下节点，链表为空时一并删除
+func (c *Cache) unlink(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	l := c.freqs[kv.freq]
+	l.Remove(ele)
+	if l.Len() == 0 {
+		delete(c.freqs, kv.freq)
+	}
+}
+
+// 从链表和字典中删除节点，并更新内存
+func (c *Cache) removeElement(ele *list.Element) {
+	c.unlink(ele)
+	kv := ele.Value.(*entry)
+	delete(c.cache, kv.key)
+	c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
@@ -0,0 +1,95 @@
+package lfu
+
+import (
+	"geecache/lru"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.Add("key1", String("1234"))
+	if v, ok := lfu.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := lfu.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+func TestRemoveLeastFrequent(t *testing.T) {
+	keys := make([]string, 0)
+	lfu := New(int64(12), func(key string, value lru.Value) {
+		keys = append(keys, key)
+	})
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	lfu.Add("k3", String("v3"))
+	// k1 访问多次，k2 访问一次，k3 没有访问
+	lfu.Get("k1")
+	lfu.Get("k1")
+	lfu.Get("k2")
+	lfu.Add("k4", String("v4"))
+	lfu.Add("k5", String("v5"))
+
+	expect := []string{"k3", "k4"}
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Call OnEvicted failed, expect keys equals to %s, got %s", expect, keys)
+	}
+	if _, ok := lfu.Get("k1"); !ok || lfu.Len() != 3 {
+		t.Fatalf("frequent key k1 should not be removed")
+	}
+}
+
+func TestAddWhenAllFrequent(t *testing.T) {
+	lfu := New(int64(6), nil)
+	lfu.Add("a", String("1"))
+	lfu.Add("b", String("2"))
+	lfu.Add("c", String("3"))
+	// 已有条目的访问次数都大于 1，新条目仍然可以加入
+	lfu.Get("a")
+	lfu.Get("b")
+	lfu.Get("c")
+	lfu.Add("d", String("4"))
+	if _, ok := lfu.Get("d"); !ok {
+		t.Fatalf("new key d should not be evicted right after Add")
+	}
+	if lfu.Len() != 3 || lfu.Bytes() != 6 {
+		t.Fatalf("expect 3 entries in 6 bytes, got %d entries in %d bytes", lfu.Len(), lfu.Bytes())
+	}
+}
+
+func TestRemove(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	lfu.Get("k2")
+	if !lfu.Remove("k1") || lfu.Len() != 1 || lfu.Bytes() != 4 {
+		t.Fatalf("Remove k1 failed")
+	}
+	// minFreq 对应的链表被删空之后仍然可以淘汰
+	lfu.RemoveOldest()
+	if lfu.Len() != 0 || lfu.Bytes() != 0 {
+		t.Fatalf("RemoveOldest after Remove failed")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.AddWithExpire("k1", String("v1"), time.Now().Add(-time.Second))
+	lfu.AddWithExpire("k2", String("v2"), time.Now().Add(-time.Second))
+	lfu.Add("k3", String("v3"))
+	if _, ok := lfu.Get("k1"); ok {
+		t.Fatalf("expired k1 should be a miss")
+	}
+	if n := lfu.RemoveExpired(); n != 1 || lfu.Len() != 1 || lfu.Bytes() != 4 {
+		t.Fatalf("RemoveExpired removed %d keys, %d left", n, lfu.Len())
+	}
+}
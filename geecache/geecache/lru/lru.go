@@ -1,6 +1,9 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"time"
+)
 
 type Cache struct {
 	//cache允许最大内存
@@ -18,6 +21,13 @@ type Cache struct {
 type entry struct {
 	key   string
 	value Value
+	// 过期时间，零值表示永不过期
+	expire time.Time
+}
+
+// 判断条目在 now 时刻是否已经过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && !now.Before(e.expire)
 }
 
 // Value 用于计算一个条目的内存大小
@@ -36,11 +46,15 @@ func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
 	}
 }
 
-// 查找功能
+// 查找功能，已经过期的条目视为未命中，并顺便删除
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	if ele, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ele)
 		kv := ele.Value.(*entry)
+		if kv.expired(time.Now()) {
+			c.removeElement(ele)
+			return nil, false
+		}
+		c.ll.MoveToFront(ele)
 		return kv.value, true
 	}
 	return
@@ -50,21 +64,90 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 func (c *Cache) RemoveOldest() {
 	ele := c.ll.Back()
 	if ele != nil {
-		c.ll.Remove(ele)
+		c.removeElement(ele)
+	}
+}
+
+// 查找但不改变访问顺序，已经过期的条目视为不存在，但不会删除
+func (c *Cache) Peek(key string) (value Value, expire time.Time, ok bool) {
+	if ele, ok := c.cache[key]; ok {
 		kv := ele.Value.(*entry)
-		//从字典中删除
-		delete(c.cache, kv.key)
-		//更新当前所用内存
-		c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.OnEvicted != nil {
-			//调用回调函数
-			c.OnEvicted(kv.key, kv.value)
+		if !kv.expired(time.Now()) {
+			return kv.value, kv.expire, true
+		}
+	}
+	return
+}
+
+// 返回最久没有访问的条目，即下一个会被淘汰的条目，不改变访问顺序
+func (c *Cache) Oldest() (key string, value Value, expire time.Time, ok bool) {
+	if ele := c.ll.Back(); ele != nil {
+		kv := ele.Value.(*entry)
+		return kv.key, kv.value, kv.expire, true
+	}
+	return
+}
+
+// 从旧到新遍历所有未过期的条目，不改变访问顺序，fn 返回 false 时停止。
+// 按遍历的顺序重新 Add 可以还原访问顺序
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now()
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		kv := ele.Value.(*entry)
+		if kv.expired(now) {
+			continue
 		}
+		if !fn(kv.key, kv.value, kv.expire) {
+			return
+		}
+	}
+}
+
+// 删除指定的条目，返回条目是否存在
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+		return true
 	}
+	return false
 }
 
-// 添加
+// 删除所有已经过期的条目，返回删除的个数
+func (c *Cache) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for ele := c.ll.Back(); ele != nil; {
+		prev := ele.Prev()
+		if ele.Value.(*entry).expired(now) {
+			c.removeElement(ele)
+			n++
+		}
+		ele = prev
+	}
+	return n
+}
+
+// 从链表和字典中删除节点，并更新内存
+func (c *Cache) removeElement(ele *list.Element) {
+	c.ll.Remove(ele)
+	kv := ele.Value.(*entry)
+	//从字典中删除
+	delete(c.cache, kv.key)
+	//更新当前所用内存
+	c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.OnEvicted != nil {
+		//调用回调函数
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// 添加，永不过期
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// 添加，并指定过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
 	//如果键存在，则更新对应节点的值，并将该节点移到队首
 	if ele, ok := c.cache[key]; ok {
 		c.ll.MoveToFront(ele)
@@ -72,9 +155,10 @@ func (c *Cache) Add(key string, value Value) {
 		//更新当前所用内存
 		c.nbytes += int64(value.Len()) - int64(kv.value.Len())
 		kv.value = value
+		kv.expire = expire
 	} else {
 		//不存在则添加到队首
-		ele := c.ll.PushFront(&entry{key, value})
+		ele := c.ll.PushFront(&entry{key, value, expire})
 		//更新字典
 		c.cache[key] = ele
 		//更新当前所用内存
@@ -86,6 +170,19 @@ func (c *Cache) Add(key string, value Value) {
 	}
 }
 
+// 修改最大内存，变小时立即淘汰多出来的条目，0 表示不限制
+func (c *Cache) SetMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+	for c.maxBytes != 0 && c.maxBytes < c.nbytes {
+		c.RemoveOldest()
+	}
+}
+
 func (c *Cache) Len() int {
 	return c.ll.Len()
 }
+
+// 返回当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
@@ -3,6 +3,7 @@ package lru
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -55,3 +56,77 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestRemove(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	if !lru.Remove("key1") || lru.Len() != 0 || lru.nbytes != 0 {
+		t.Fatalf("Remove key1 failed")
+	}
+	if lru.Remove("key1") {
+		t.Fatalf("Remove missing key1 should return false")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.AddWithExpire("key1", String("1234"), time.Now().Add(-time.Second))
+	lru.AddWithExpire("key2", String("5678"), time.Now().Add(time.Hour))
+	lru.Add("key3", String("90"))
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 2 {
+		t.Fatalf("expired key1 should be a miss")
+	}
+	if v, ok := lru.Get("key2"); !ok || string(v.(String)) != "5678" {
+		t.Fatalf("cache hit key2=5678 failed")
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	past := time.Now().Add(-time.Second)
+	lru.AddWithExpire("k1", String("v1"), past)
+	lru.Add("k2", String("v2"))
+	lru.AddWithExpire("k3", String("v3"), past)
+	if n := lru.RemoveExpired(); n != 2 || lru.Len() != 1 {
+		t.Fatalf("RemoveExpired removed %d keys, %d left", n, lru.Len())
+	}
+	if lru.nbytes != int64(len("k2")+len("v2")) {
+		t.Fatalf("nbytes = %d after RemoveExpired", lru.nbytes)
+	}
+	expect := []string{"k1", "k3"}
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
+	}
+}
+
+func TestRange(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1"))
+	lru.Add("key2", String("2"))
+	lru.AddWithExpire("key3", String("3"), time.Now().Add(-time.Second))
+	lru.Add("key4", String("4"))
+	lru.Get("key1")
+
+	var keys []string
+	lru.Range(func(key string, value Value, expire time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if expect := []string{"key2", "key4", "key1"}; !reflect.DeepEqual(keys, expect) {
+		t.Fatalf("Range = %v, expect %v", keys, expect)
+	}
+}
+
+func TestSetMaxBytes(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	lru.Add("key2", String("1234"))
+	lru.Add("key3", String("1234"))
+	lru.SetMaxBytes(int64(len("key2" + "1234" + "key3" + "1234")))
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 2 {
+		t.Fatalf("SetMaxBytes should evict key1, len %d", lru.Len())
+	}
+}
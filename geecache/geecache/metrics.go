@@ -0,0 +1,199 @@
+/*
+以 Prometheus 的文本格式导出统计数据，不依赖 Prometheus 的客户端库
+*/
+package geecache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 载入耗时直方图的桶，单位是秒
+var loadBuckets = [...]float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// 累计的直方图，counts[i] 是不超过 loadBuckets[i] 的次数
+type histogram struct {
+	mu     sync.Mutex
+	counts [len(loadBuckets)]int64
+	count  int64
+	sum    float64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, le := range loadBuckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// 返回当前数据的副本
+func (h *histogram) snapshot() (counts [len(loadBuckets)]int64, count int64, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.counts, h.count, h.sum
+}
+
+// 访问其它节点的请求按 group、节点、方法和结果计数
+type peerRequestKey struct {
+	group, peer, method, status string
+}
+
+var (
+	peerRequestsMu sync.Mutex
+	peerRequests   = make(map[peerRequestKey]int64)
+)
+
+// 记录一次访问其它节点的请求，status 是 ok、not_found 或者 error
+func recordPeerRequest(group, peer, method string, err error) {
+	status := "ok"
+	switch {
+	//rpc 返回的错误只剩下字符串
+	case errors.Is(err, ErrNotFound) || err != nil && err.Error() == ErrNotFound.Error():
+		status = "not_found"
+	case err != nil:
+		status = "error"
+	}
+	peerRequestsMu.Lock()
+	peerRequests[peerRequestKey{group, peer, method, status}]++
+	peerRequestsMu.Unlock()
+}
+
+// MetricsHandler 返回 Prometheus 格式的统计数据，一般挂在 /metrics 上：
+// 每个 Group 的请求、命中、载入次数，载入耗时的直方图，
+// mainCache 和 hotCache 的内存、条目数和淘汰次数，以及访问其它节点的请求个数
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		writeMetrics(bw)
+		bw.Flush()
+	})
+}
+
+// Group 的计数器，名称、说明和取值的方法
+var groupCounters = []struct {
+	name, help string
+	value      func(s *Stats) int64
+}{
+	{"geecache_gets_total", "Get requests, including requests from peers.", func(s *Stats) int64 { return s.Gets.Get() }},
+	{"geecache_cache_hits_total", "Gets served from the main or hot cache.", func(s *Stats) int64 { return s.CacheHits.Get() }},
+	{"geecache_cache_misses_total", "Gets that missed the cache and had to be loaded.", func(s *Stats) int64 { return s.Loads.Get() }},
+	{"geecache_negative_hits_total", "Gets served from the negative cache.", func(s *Stats) int64 { return s.NegativeHits.Get() }},
+	{"geecache_loads_deduped_total", "Loads actually performed after singleflight.", func(s *Stats) int64 { return s.LoadsDeduped.Get() }},
+	{"geecache_peer_loads_total", "Values loaded from peers.", func(s *Stats) int64 { return s.PeerLoads.Get() }},
+	{"geecache_peer_errors_total", "Failed loads from peers.", func(s *Stats) int64 { return s.PeerErrors.Get() }},
+	{"geecache_local_loads_total", "Values loaded by the Getter.", func(s *Stats) int64 { return s.LocalLoads.Get() }},
+	{"geecache_local_load_errors_total", "Failed loads by the Getter.", func(s *Stats) int64 { return s.LocalLoadErrs.Get() }},
+	{"geecache_server_requests_total", "Get requests received from peers.", func(s *Stats) int64 { return s.ServerRequests.Get() }},
+}
+
+// mainCache 和 hotCache 的指标，名称、类型、说明和取值的方法
+var cacheMetrics = []struct {
+	name, typ, help string
+	value           func(cs CacheStats) int64
+}{
+	{"geecache_cache_bytes", "gauge", "Bytes in use by the cache.", func(cs CacheStats) int64 { return cs.Bytes }},
+	{"geecache_cache_items", "gauge", "Items in the cache.", func(cs CacheStats) int64 { return cs.Items }},
+	{"geecache_cache_evictions_total", "counter", "Items evicted because the cache was full, excluding removals and expirations.", func(cs CacheStats) int64 { return cs.Evictions }},
+}
+
+func writeMetrics(w *bufio.Writer) {
+	var gs []*Group
+	for _, name := range ListGroups() {
+		if g := GetGroup(name); g != nil {
+			gs = append(gs, g)
+		}
+	}
+
+	for _, c := range groupCounters {
+		writeHeader(w, c.name, "counter", c.help)
+		for _, g := range gs {
+			fmt.Fprintf(w, "%s{group=%s} %d\n", c.name, quote(g.name), c.value(&g.Stats))
+		}
+	}
+
+	writeHeader(w, "geecache_cache_bytes_limit", "gauge", "Memory limit of the group set by NewGroup or SetCacheBytes.")
+	for _, g := range gs {
+		fmt.Fprintf(w, "geecache_cache_bytes_limit{group=%s} %d\n", quote(g.name), g.CacheBytes())
+	}
+	stats := make([][2]CacheStats, len(gs))
+	for i, g := range gs {
+		stats[i] = [2]CacheStats{g.CacheStats(MainCache), g.CacheStats(HotCache)}
+	}
+	for _, m := range cacheMetrics {
+		writeHeader(w, m.name, m.typ, m.help)
+		for i, g := range gs {
+			for j, cache := range []string{"main", "hot"} {
+				fmt.Fprintf(w, "%s{group=%s,cache=%s} %d\n", m.name, quote(g.name), quote(cache), m.value(stats[i][j]))
+			}
+		}
+	}
+
+	writeHeader(w, "geecache_load_duration_seconds", "histogram", "Time spent loading a missed key from a peer or the Getter.")
+	for _, g := range gs {
+		for _, source := range []struct {
+			name string
+			h    *histogram
+		}{{"peer", &g.peerLatency}, {"local", &g.localLatency}} {
+			labels := fmt.Sprintf("group=%s,source=%s", quote(g.name), quote(source.name))
+			counts, count, sum := source.h.snapshot()
+			for i, le := range loadBuckets {
+				fmt.Fprintf(w, "geecache_load_duration_seconds_bucket{%s,le=%s} %d\n", labels, quote(strconv.FormatFloat(le, 'g', -1, 64)), counts[i])
+			}
+			fmt.Fprintf(w, "geecache_load_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, count)
+			fmt.Fprintf(w, "geecache_load_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(sum, 'g', -1, 64))
+			fmt.Fprintf(w, "geecache_load_duration_seconds_count{%s} %d\n", labels, count)
+		}
+	}
+
+	writeHeader(w, "geecache_peer_requests_total", "counter", "Requests sent to peers by method and status.")
+	peerRequestsMu.Lock()
+	keys := make([]peerRequestKey, 0, len(peerRequests))
+	values := make(map[peerRequestKey]int64, len(peerRequests))
+	for k, v := range peerRequests {
+		keys = append(keys, k)
+		values[k] = v
+	}
+	peerRequestsMu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		if a.peer != b.peer {
+			return a.peer < b.peer
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "geecache_peer_requests_total{group=%s,peer=%s,method=%s,status=%s} %d\n",
+			quote(k.group), quote(k.peer), quote(k.method), quote(k.status), values[k])
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// 标签值需要转义反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
@@ -0,0 +1,56 @@
+package geecache
+
+import (
+	"context"
+	pb "geecache/geecachepb"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	g := NewGroup("metrics", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	g.Get("Tom")
+	g.Get("Tom")
+
+	srv := httptest.NewServer(NewHTTPPool(""))
+	defer srv.Close()
+	pool := NewHTTPPool("http://self")
+	pool.Set(srv.URL)
+	if err := pool.getters[srv.URL].Get(context.Background(), &pb.Request{Group: "metrics", Key: "Jack"}, &pb.Response{}); err != nil {
+		t.Fatal(err)
+	}
+	pool.getters[srv.URL].Get(context.Background(), &pb.Request{Group: "missing", Key: "Jack"}, &pb.Response{})
+
+	w := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		`# TYPE geecache_gets_total counter`,
+		`geecache_gets_total{group="metrics"} 3`,
+		`geecache_cache_hits_total{group="metrics"} 1`,
+		`geecache_cache_misses_total{group="metrics"} 2`,
+		`geecache_cache_items{group="metrics",cache="main"} 2`,
+		`geecache_cache_bytes{group="metrics",cache="main"} 14`,
+		`geecache_cache_evictions_total{group="metrics",cache="main"} 0`,
+		`geecache_cache_bytes_limit{group="metrics"} 2048`,
+		`# TYPE geecache_load_duration_seconds histogram`,
+		`geecache_load_duration_seconds_bucket{group="metrics",source="local",le="+Inf"} 2`,
+		`geecache_load_duration_seconds_count{group="metrics",source="local"} 2`,
+		`geecache_peer_requests_total{group="metrics",peer="` + srv.URL + `",method="Get",status="ok"} 1`,
+		`geecache_peer_requests_total{group="missing",peer="` + srv.URL + `",method="Get",status="error"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("metrics should contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if q := quote("a\"b\\c\nd"); q != `"a\"b\\c\nd"` {
+		t.Fatalf("quote = %s", q)
+	}
+}
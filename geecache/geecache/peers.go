@@ -1,10 +1,50 @@
 package geecache
 
-import pb "geecache/geecachepb"
+import (
+	"context"
+	"fmt"
+	"geecache/consistenthash"
+	pb "geecache/geecachepb"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultReplicas = 50
+
+const (
+	//默认从注册中心拉取节点列表的间隔
+	defaultWatchInterval = 10 * time.Second
+	//默认的心跳间隔，比注册中心默认的5分钟超时时间短1分钟
+	defaultHeartbeat = 4 * time.Minute
+)
 
 //通过key找到对应的PeerGetter，使用一致性哈希算法
 type PeerPicker interface {
 	PickPeer(key string) (peer PeerGetter, ok bool)
+	// 返回 key 依次对应的最多 n 个不同节点，第一个是拥有者，其余的是副本，
+	// 自己对应的位置为 nil
+	PickPeers(key string, n int) []PeerGetter
+	// 返回除自己以外的所有节点，用于广播删除等操作
+	GetAll() []PeerGetter
+}
+
+//ctx 中标记请求是其它节点转发来的
+type forwardedKey struct{}
+
+//标记 ctx 对应的请求来自其它节点，载入时只使用本机的缓存和 Getter，不会再转发，
+//否则拥有者不可用时，收到故障转移请求的副本会再去访问一次拥有者
+func withForwarded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forwardedKey{}, true)
+}
+
+//请求是否来自其它节点
+func isForwarded(ctx context.Context) bool {
+	forwarded, _ := ctx.Value(forwardedKey{}).(bool)
+	return forwarded
 }
 
 //从对应的group和对应的key找到对应的值，使用http客户端
@@ -13,5 +53,367 @@ type PeerPicker interface {
 // }
 
 type PeerGetter interface {
-	Get(in *pb.Request, out *pb.Response) error
+	// ctx 的截止时间会传递给远程节点
+	Get(ctx context.Context, in *pb.Request, out *pb.Response) error
+	// 一次获取多个 key，每个 key 的结果和错误在 out 中单独返回
+	GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error
+	// 删除远程节点本地缓存中的 key，远程节点不会再向其它节点转发
+	Remove(in *pb.Request) error
+	// 把值写入远程节点的缓存
+	Set(in *pb.SetRequest) error
+}
+
+//一致性哈希环，以及每个节点对应的PeerGetter，HTTPPool 和 RPCPool 共用
+type peerRing struct {
+	//用来记录自己的地址
+	self string
+	mu   sync.Mutex
+	//节点间通讯地址的map，key是具体的节点的地址，value是对应节点的PeerGetter
+	getters map[string]PeerGetter
+	//PickPeer、PickPeers 和 GetAll 返回的PeerGetter，需要负载的算法包装成 loadGetter，
+	//每个节点只包装一次，同一个节点每次返回的值相等，Group.Remove 和 GetMany 依赖这一点
+	picked map[string]PeerGetter
+	//每个节点的权重，更换选择算法时用来重建
+	weights map[string]int
+	//根据具体的key选择节点的算法的实例，默认是一致性哈希环
+	peers consistenthash.Picker
+	//创建选择算法的实例，nil 表示使用一致性哈希环
+	newPicker func() consistenthash.Picker
+	//为一个节点创建PeerGetter
+	newGetter func(peer string) PeerGetter
+}
+
+//Bounded 这类需要知道节点负载的选择算法
+type loadTracker interface {
+	Inc(node string)
+	Done(node string)
+}
+
+//在请求远程节点期间把节点的负载加一
+type loadGetter struct {
+	PeerGetter
+	peer    string
+	tracker loadTracker
+}
+
+func (l *loadGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	l.tracker.Inc(l.peer)
+	defer l.tracker.Done(l.peer)
+	return l.PeerGetter.Get(ctx, in, out)
+}
+
+func (l *loadGetter) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	l.tracker.Inc(l.peer)
+	defer l.tracker.Done(l.peer)
+	return l.PeerGetter.GetMany(ctx, in, out)
+}
+
+//更换根据key选择节点的算法，例如 consistenthash.NewRendezvous、NewJump、NewBounded，
+//已有的节点和权重保持不变，所有节点必须使用相同的算法。
+//NewBounded 选出的拥有者随负载变化，Set 写入的节点之后不一定能被读到，
+//所以不要和 Group.Set、WithWriteReplicas 一起使用
+func (r *peerRing) SetPicker(newPicker func() consistenthash.Picker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.newPicker = newPicker
+	if r.peers == nil {
+		return
+	}
+	r.peers = r.picker()
+	for peer, weight := range r.weights {
+		r.peers.AddWithWeight(peer, weight)
+		r.picked[peer] = r.wrap(peer)
+	}
+}
+
+//创建一个空的选择算法的实例
+func (r *peerRing) picker() consistenthash.Picker {
+	if r.newPicker == nil {
+		return consistenthash.New(defaultReplicas, nil)
+	}
+	return r.newPicker()
+}
+
+//第一次添加节点时初始化
+func (r *peerRing) init() {
+	if r.peers == nil {
+		r.peers = r.picker()
+		r.getters = make(map[string]PeerGetter)
+		r.picked = make(map[string]PeerGetter)
+		r.weights = make(map[string]int)
+	}
+}
+
+// Log info with server name
+func (r *peerRing) Log(format string, v ...interface{}) {
+	log.Printf("[Server %s] %s", r.self, fmt.Sprintf(format, v...))
+}
+
+//重新设置所有的节点
+func (r *peerRing) Set(peers ...string) {
+	weights := make(map[string]int, len(peers))
+	for _, peer := range peers {
+		weights[peer] = 1
+	}
+	r.SetWeighted(weights)
+}
+
+//重新设置所有的节点，并指定每个节点的权重，例如按节点的内存大小设置，
+//节点分到的 key 的数量大致和权重成正比
+func (r *peerRing) SetWeighted(peers map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	//关闭旧的连接
+	for _, getter := range r.getters {
+		if c, ok := getter.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	//初始化选择节点的算法
+	r.peers = r.picker()
+	//初始化getters
+	r.getters = make(map[string]PeerGetter, len(peers))
+	r.picked = make(map[string]PeerGetter, len(peers))
+	r.weights = make(map[string]int, len(peers))
+	//添加传入的节点，并为每一个节点创建一个PeerGetter
+	for peer, weight := range peers {
+		r.peers.AddWithWeight(peer, weight)
+		r.getters[peer] = r.newGetter(peer)
+		r.picked[peer] = r.wrap(peer)
+		r.weights[peer] = weight
+	}
+}
+
+//增加节点，已经存在的节点保持不变，只有大约 1/N 的 key 会移动到新节点上
+func (r *peerRing) AddPeers(peers ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	for _, peer := range peers {
+		if _, ok := r.getters[peer]; ok {
+			continue
+		}
+		r.peers.Add(peer)
+		r.getters[peer] = r.newGetter(peer)
+		r.picked[peer] = r.wrap(peer)
+		r.weights[peer] = 1
+	}
+}
+
+//增加一个带权重的节点，节点已经存在时保持不变
+func (r *peerRing) AddWeightedPeer(peer string, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	if _, ok := r.getters[peer]; ok {
+		return
+	}
+	r.peers.AddWithWeight(peer, weight)
+	r.getters[peer] = r.newGetter(peer)
+	r.picked[peer] = r.wrap(peer)
+	r.weights[peer] = weight
+}
+
+//删除节点，只有被删除节点上的 key 会移动到其它节点
+func (r *peerRing) RemovePeers(peers ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, peer := range peers {
+		getter, ok := r.getters[peer]
+		if !ok {
+			continue
+		}
+		r.peers.Remove(peer)
+		delete(r.getters, peer)
+		delete(r.picked, peer)
+		delete(r.weights, peer)
+		if c, ok := getter.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+//和 registry.Heartbeat 一样向geerpc的注册中心定时发送心跳，让其它节点通过 Watch 发现自己，
+//发送失败时不会停止，下一次继续发送，返回的函数用于停止发送
+func (r *peerRing) Heartbeat(registryAddr string, duration time.Duration) (stop func()) {
+	if duration <= 0 {
+		duration = defaultHeartbeat
+	}
+	r.sendHeartbeat(registryAddr)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(duration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.sendHeartbeat(registryAddr)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+//发送一次心跳，地址放在 X-Geerpc-Server 中
+func (r *peerRing) sendHeartbeat(registryAddr string) {
+	req, _ := http.NewRequest(http.MethodPost, registryAddr, nil)
+	req.Header.Set("X-Geerpc-Server", r.self)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Println("[geecache] heart beat err:", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+//定时从geerpc的注册中心拉取存活的节点，增量地更新哈希环，
+//新加入的节点会被添加，心跳超时的节点会被删除，返回的函数用于停止拉取
+func (r *peerRing) Watch(registryAddr string, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	r.refresh(registryAddr)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.refresh(registryAddr)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+//从注册中心拉取存活的节点，和 xclient.GeeRegistryDiscovery 一样从 X-Geerpc-Servers 中读取，
+//每次都关闭响应体，定时拉取时不会泄漏连接
+func fetchPeers(registryAddr string) ([]string, error) {
+	resp, err := http.Get(registryAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned: %v", resp.Status)
+	}
+	var servers []string
+	for _, server := range strings.Split(resp.Header.Get("X-Geerpc-Servers"), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+//根据注册中心的节点列表更新哈希环，拉取失败或者列表为空时保持不变
+func (r *peerRing) refresh(registryAddr string) {
+	servers, err := fetchPeers(registryAddr)
+	if err != nil {
+		log.Println("[geecache] fetch peers err:", err)
+		return
+	}
+	if len(servers) == 0 {
+		return
+	}
+	alive := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		alive[server] = true
+	}
+	var dead []string
+	r.mu.Lock()
+	for peer := range r.getters {
+		if !alive[peer] {
+			dead = append(dead, peer)
+		}
+	}
+	r.mu.Unlock()
+	r.AddPeers(servers...)
+	r.RemovePeers(dead...)
+}
+
+//根据具体的key选择节点，返回对应的PeerGetter
+func (r *peerRing) PickPeer(key string) (PeerGetter, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.peers == nil {
+		return nil, false
+	}
+	//根据具体的key选择节点
+	if peer := r.peers.Get(key); peer != "" && peer != r.self {
+		r.Log("Pick peer %s", peer)
+		//返回对应的PeerGetter
+		return r.picked[peer], true
+	}
+	return nil, false
+}
+
+//返回 key 在环上依次对应的最多 n 个节点的PeerGetter，自己对应的位置为 nil
+func (r *peerRing) PickPeers(key string, n int) []PeerGetter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.peers == nil {
+		return nil
+	}
+	peers := r.peers.GetN(key, n)
+	getters := make([]PeerGetter, len(peers))
+	for i, peer := range peers {
+		if peer != r.self {
+			getters[i] = r.picked[peer]
+		}
+	}
+	return getters
+}
+
+//包装节点对应的PeerGetter，需要负载的算法在请求期间记录负载
+func (r *peerRing) wrap(peer string) PeerGetter {
+	if tracker, ok := r.peers.(loadTracker); ok {
+		return &loadGetter{PeerGetter: r.getters[peer], peer: peer, tracker: tracker}
+	}
+	return r.getters[peer]
+}
+
+//返回除自己以外的所有节点的PeerGetter
+func (r *peerRing) GetAll() []PeerGetter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	getters := make([]PeerGetter, 0, len(r.picked))
+	for peer, getter := range r.picked {
+		if peer != r.self {
+			getters = append(getters, getter)
+		}
+	}
+	return getters
+}
+
+//返回自己的地址，以及所有节点（包括自己）和它们的权重
+func (r *peerRing) Peers() (self string, weights map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	weights = make(map[string]int, len(r.weights))
+	for peer, weight := range r.weights {
+		weights[peer] = weight
+	}
+	return r.self, weights
+}
+
+//返回 key 在环上依次对应的所有节点的地址，第一个是拥有者
+func (r *peerRing) Owners(key string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.peers == nil {
+		return nil
+	}
+	return r.peers.GetN(key, len(r.weights))
 }
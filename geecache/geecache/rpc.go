@@ -0,0 +1,175 @@
+/*
+通过 geerpc 访问其它节点，节点之间保持长连接，一个连接上可以同时处理多个请求
+*/
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pb "geecache/geecachepb"
+	"geerpc"
+	"sync"
+)
+
+// RPCPool 和 HTTPPool 一样实现了 PeerPicker，但是通过 geerpc 调用其它节点上的 GroupCache 服务，
+// 节点地址使用 geerpc.XDial 的格式，例如 tcp@localhost:8001、http@localhost:8001
+type RPCPool struct {
+	//一致性哈希环，以及每个节点对应的rpcGetter
+	peerRing
+	//连接其它节点时使用的选项，nil 表示使用 geerpc.DefaultOption
+	opt *geerpc.Option
+}
+
+func NewRPCPool(self string, opt *geerpc.Option) *RPCPool {
+	p := &RPCPool{opt: opt}
+	p.peerRing = peerRing{
+		self: self,
+		newGetter: func(peer string) PeerGetter {
+			return &rpcGetter{addr: peer, opt: p.opt}
+		},
+	}
+	return p
+}
+
+// 在 server 上注册 GroupCache 服务，server 为 nil 时注册到 geerpc.DefaultServer
+func (p *RPCPool) Register(server *geerpc.Server) error {
+	if server == nil {
+		server = geerpc.DefaultServer
+	}
+	return server.Register(&GroupCache{})
+}
+
+var _ PeerPicker = (*RPCPool)(nil)
+
+// GroupCache 是 geecachepb 中声明的 GroupCache 服务在 geerpc 上的实现，
+// 处理其它节点发来的请求，服务名和方法名与 geecachepb.proto 一致
+type GroupCache struct{}
+
+//获取本节点上的缓存值
+func (s *GroupCache) Get(in *pb.Request, out *pb.Response) error {
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return fmt.Errorf("no such group: %s", in.GetGroup())
+	}
+	group.Stats.ServerRequests.Add(1)
+	//GroupCache 服务只处理其它节点转发来的请求，只在本机载入
+	view, err := group.GetContext(withForwarded(context.Background()), in.GetKey())
+	//geerpc 只传递错误信息，统一成 ErrNotFound 的信息，调用方据此还原
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	out.Value = view.ByteSlice()
+	out.Expire = unixNano(view.expire)
+	return nil
+}
+
+//一次获取本节点上的多个缓存值
+func (s *GroupCache) GetMany(in *pb.BatchRequest, out *pb.BatchResponse) error {
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return fmt.Errorf("no such group: %s", in.GetGroup())
+	}
+	group.Stats.ServerRequests.Add(int64(len(in.GetKeys())))
+	batchResponse(group.GetManyContext(withForwarded(context.Background()), in.GetKeys()), out)
+	return nil
+}
+
+//只删除本节点的缓存，不再转发给其它节点
+func (s *GroupCache) Remove(in *pb.Request, out *pb.Response) error {
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return fmt.Errorf("no such group: %s", in.GetGroup())
+	}
+	group.removeLocally(in.GetKey())
+	return nil
+}
+
+//把值写入本节点的缓存
+func (s *GroupCache) Set(in *pb.SetRequest, out *pb.Response) error {
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return fmt.Errorf("no such group: %s", in.GetGroup())
+	}
+	group.populateCache(in.GetKey(), ByteView{b: in.GetValue()}, unixNanoTime(in.GetExpire()))
+	return nil
+}
+
+//通过geerpc访问一个远程节点，第一次使用时建立连接，连接断开后自动重连
+type rpcGetter struct {
+	addr string
+	opt  *geerpc.Option
+
+	mu     sync.Mutex
+	client *geerpc.Client
+}
+
+//返回可用的连接，没有则重新建立
+func (r *rpcGetter) dial() (*geerpc.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != nil && r.client.IsAvailable() {
+		return r.client, nil
+	}
+	if r.client != nil {
+		_ = r.client.Close()
+		r.client = nil
+	}
+	client, err := geerpc.XDial(r.addr, r.opt)
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+	return client, nil
+}
+
+//调用远程节点上 GroupCache 服务的方法
+func (r *rpcGetter) call(ctx context.Context, method string, in interface{ GetGroup() string }, out interface{}) (err error) {
+	defer func() {
+		recordPeerRequest(in.GetGroup(), r.addr, method, err)
+	}()
+	client, err := r.dial()
+	if err != nil {
+		return err
+	}
+	return client.Call(ctx, "GroupCache."+method, in, out)
+}
+
+//实现了PeerGetter接口的Get方法，ctx 结束时不再等待结果
+func (r *rpcGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	err := r.call(ctx, "Get", in, out)
+	if err != nil && err.Error() == ErrNotFound.Error() {
+		return ErrNotFound
+	}
+	return err
+}
+
+//实现了PeerGetter接口的GetMany方法
+func (r *rpcGetter) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	return r.call(ctx, "GetMany", in, out)
+}
+
+//实现了PeerGetter接口的Remove方法
+func (r *rpcGetter) Remove(in *pb.Request) error {
+	return r.call(context.Background(), "Remove", in, &pb.Response{})
+}
+
+//实现了PeerGetter接口的Set方法
+func (r *rpcGetter) Set(in *pb.SetRequest) error {
+	return r.call(context.Background(), "Set", in, &pb.Response{})
+}
+
+//关闭连接，节点被移除时调用
+func (r *rpcGetter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client == nil {
+		return nil
+	}
+	err := r.client.Close()
+	r.client = nil
+	return err
+}
@@ -0,0 +1,71 @@
+package geecache
+
+import (
+	"context"
+	pb "geecache/geecachepb"
+	"geerpc"
+	"net"
+	"testing"
+)
+
+// 启动一个注册了 GroupCache 服务的 geerpc 服务端，返回 tcp@addr 格式的地址
+func startRPCServer(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	server := geerpc.NewServer()
+	if err := NewRPCPool("", nil).Register(server); err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(lis)
+	return "tcp@" + lis.Addr().String()
+}
+
+func TestRPCGetter(t *testing.T) {
+	loads := 0
+	gee := NewGroup("rpc", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	getter := &rpcGetter{addr: startRPCServer(t)}
+	defer getter.Close()
+
+	out := &pb.Response{}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "rpc", Key: "Tom"}, out); err != nil || string(out.Value) != "Tom" {
+		t.Fatalf("rpc Get Tom failed: %v", err)
+	}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "unknown", Key: "Tom"}, &pb.Response{}); err == nil {
+		t.Fatalf("rpc Get from unknown group should fail")
+	}
+	if err := getter.Remove(&pb.Request{Group: "rpc", Key: "Tom"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gee.Get("Tom"); err != nil || loads != 2 {
+		t.Fatalf("removed Tom should be loaded again, loads = %d", loads)
+	}
+	if err := getter.Set(&pb.SetRequest{Group: "rpc", Key: "Jack", Value: []byte("589")}); err != nil {
+		t.Fatal(err)
+	}
+	if view, err := gee.Get("Jack"); err != nil || view.String() != "589" || loads != 2 {
+		t.Fatalf("rpc Set Jack failed: %v", err)
+	}
+}
+
+func TestRPCPool(t *testing.T) {
+	addr := startRPCServer(t)
+	pool := NewRPCPool("tcp@self", nil)
+	pool.Set("tcp@self", addr)
+	if n := len(pool.GetAll()); n != 1 {
+		t.Fatalf("GetAll should not include self, got %d peers", n)
+	}
+	for _, key := range []string{"Tom", "Jack", "Sam", "Lily", "Lucy"} {
+		if peer, ok := pool.PickPeer(key); ok {
+			if _, isRPC := peer.(*rpcGetter); !isRPC {
+				t.Fatalf("RPCPool should pick rpcGetter, got %T", peer)
+			}
+		}
+	}
+}
@@ -1,15 +1,46 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// fn 调用了 runtime.Goexit（例如测试中的 t.FailNow），等待者得到这个错误
+var errGoexit = errors.New("runtime.Goexit was called")
+
+// fn 发生 panic 时保存 panic 的值和堆栈，在每一个等待者中重新 panic
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
 
 //正在进行中的，或已经结束的请求
 type call struct {
-	// wg 用于实现并发控制，避免重入
-	wg sync.WaitGroup
+	// done 在请求结束时关闭，等待者通过它得知结果已经可用
+	done chan struct{}
 	// val 用于保存请求的结果
 	val interface{}
 	// err 用于保存请求的错误信息
 	err error
+	// 除了第一个调用者以外，共享这次结果的调用者个数
+	dups int
+	// DoChan 的调用者，请求结束时把结果发送给它们
+	chans []chan<- Result
+}
+
+// Result 是 DoChan 返回的结果
+type Result struct {
+	Val interface{}
+	Err error
+	// 结果是否和其它调用者共享
+	Shared bool
 }
 
 //主要数据结构，管理不同key的请求(call)
@@ -20,31 +51,124 @@ type Group struct {
 }
 
 //针对相同的key，不论Do被调用多少次，函数fn都只会被调用一次，等待fn调用结束了，返回返回值或错误
-//保存在map，每次的新查询都会查找，找到则返回，否则就新建一个call，调用fn，然后保存到map中
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+//保存在map，每次的新查询都会查找，找到则返回，否则就新建一个call，调用fn，然后保存到map中。
+//shared 表示结果是否和其它调用者共享，fn 发生 panic 时所有调用者都会 panic
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	return g.DoContext(context.Background(), key, fn)
+}
+
+//和 Do 相同，但是等待其它调用者的结果时，ctx 结束就立即返回 ctx.Err()，
+//fn 由第一个调用者执行，应该自己使用第一个调用者的 ctx 来控制超时
+func (g *Group) DoContext(ctx context.Context, key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
 	if c, ok := g.m[key]; ok {
+		c.dups++
 		g.mu.Unlock()
 		// 第一个get(key)请求到来时，singleflight会记录当前key正在被处理，
 		// 后续的请求只需要等待第一个请求处理完成，取返回值即可
-		c.wg.Wait()
-		return c.val, c.err
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err(), true
+		}
+		if e, ok := c.err.(*panicError); ok {
+			panic(e)
+		}
+		return c.val, c.err, true
 	}
-	c := new(call)
-	c.wg.Add(1)  //发起请求前加锁
+	c := newCall()
 	g.m[key] = c //添加到 g.m，表明 key 已经有对应的请求在处理
 	g.mu.Unlock()
 
-	c.val, c.err = fn()
-	c.wg.Done() //请求结束
+	g.doCall(c, key, fn)
+	return c.val, c.err, c.dups > 0
+}
+
+// DoChan 和 Do 相同，但是不等待结果，而是返回一个接收结果的 channel。
+// fn 发生 panic 时无法传递给接收者，整个程序会崩溃，而不是让接收者永远等待
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := newCall()
+	c.chans = append(c.chans, ch)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn)
+	return ch
+}
 
+// Forget 让 key 之后的调用重新执行 fn，而不是等待正在进行中的请求，
+// 已经在等待的调用者仍然会得到正在进行中的请求的结果
+func (g *Group) Forget(key string) {
 	g.mu.Lock()
-	//删除 g.m 中的记录，因为数据会被更新，后续的请求需要重新请求
-	delete(g.m, key) //更新 g.m
+	delete(g.m, key)
 	g.mu.Unlock()
+}
 
-	return c.val, c.err
+func newCall() *call {
+	return &call{done: make(chan struct{})}
+}
+
+// 执行 fn，无论 fn 正常返回、panic 还是调用 runtime.Goexit，都会唤醒所有等待者
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	normalReturn := false
+	recovered := false
+
+	defer func() {
+		//既没有正常返回也没有 panic，说明调用了 runtime.Goexit
+		if !normalReturn && !recovered {
+			c.err = errGoexit
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		close(c.done) //请求结束
+		//删除 g.m 中的记录，因为数据会被更新，后续的请求需要重新请求，
+		//Forget 之后 g.m 中可能已经是新的请求
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+
+		if e, ok := c.err.(*panicError); ok {
+			if len(c.chans) > 0 {
+				//DoChan 的接收者无法 recover，在新的 goroutine 中 panic，让程序崩溃
+				go panic(e)
+				select {}
+			}
+			panic(e)
+		}
+		for _, ch := range c.chans {
+			ch <- Result{Val: c.val, Err: c.err, Shared: c.dups > 0}
+		}
+	}()
+
+	func() {
+		defer func() {
+			if !normalReturn {
+				//recover 只能在 defer 中直接调用
+				if r := recover(); r != nil {
+					c.err = &panicError{value: r, stack: debug.Stack()}
+				}
+			}
+		}()
+		c.val, c.err = fn()
+		normalReturn = true
+	}()
+
+	if !normalReturn {
+		recovered = true
+	}
 }
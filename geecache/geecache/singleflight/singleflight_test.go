@@ -0,0 +1,208 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err, shared := g.Do("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v.(string) != "bar" || err != nil || shared {
+		t.Fatalf("Do = %v, %v, %v", v, err, shared)
+	}
+
+	someErr := errors.New("some error")
+	if _, err, _ := g.Do("key", func() (interface{}, error) {
+		return nil, someErr
+	}); err != someErr {
+		t.Fatalf("expect %v, got %v", someErr, err)
+	}
+}
+
+func TestDoDupSuppress(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	var shared int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, s := g.Do("key", fn)
+			if v.(string) != "bar" || err != nil {
+				t.Errorf("Do = %v, %v", v, err)
+			}
+			if s {
+				atomic.AddInt32(&shared, 1)
+			}
+		}()
+	}
+	// 等所有调用者都进入 Do 之后再让 fn 返回
+	waitDups(&g, "key", n-1)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("fn should be called once, got %d", calls)
+	}
+	if shared != n {
+		t.Fatalf("all %d callers should see shared result, got %d", n, shared)
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	go g.Do("key", func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	})
+	waitDups(&g, "key", 0)
+
+	// 等待者的 ctx 结束时立即返回，不影响正在进行中的请求
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err, _ := g.DoContext(ctx, "key", nil); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+	close(release)
+}
+
+func TestDoChan(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch1 := g.DoChan("key", func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	})
+	ch2 := g.DoChan("key", func() (interface{}, error) {
+		t.Error("fn should not be called twice")
+		return nil, nil
+	})
+	close(release)
+	for _, ch := range []<-chan Result{ch1, ch2} {
+		res := <-ch
+		if res.Val.(string) != "bar" || res.Err != nil || !res.Shared {
+			t.Fatalf("DoChan = %+v", res)
+		}
+	}
+}
+
+func TestForget(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	first := g.DoChan("key", func() (interface{}, error) {
+		<-release
+		return 1, nil
+	})
+	g.Forget("key")
+
+	// Forget 之后重新执行 fn，不等待第一个请求
+	v, _, _ := g.Do("key", func() (interface{}, error) {
+		return 2, nil
+	})
+	if v.(int) != 2 {
+		t.Fatalf("expect 2 after Forget, got %v", v)
+	}
+	close(release)
+	if res := <-first; res.Val.(int) != 1 {
+		t.Fatalf("first call should still get 1, got %v", res.Val)
+	}
+}
+
+func TestPanicDo(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		panic("invalid memory address or nil pointer dereference")
+	}
+
+	const n = 5
+	var wg sync.WaitGroup
+	var panics int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					atomic.AddInt32(&panics, 1)
+				}
+			}()
+			g.Do("key", fn)
+		}()
+	}
+	waitDups(&g, "key", n-1)
+	close(release)
+
+	// 所有调用者都应该 panic，而不是永远等待
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("callers deadlocked after fn panicked")
+	}
+	if panics != n {
+		t.Fatalf("expect %d callers to panic, got %d", n, panics)
+	}
+	// panic 之后 key 可以再次使用
+	if v, err, _ := g.Do("key", func() (interface{}, error) { return "ok", nil }); err != nil || v.(string) != "ok" {
+		t.Fatalf("Do after panic = %v, %v", v, err)
+	}
+}
+
+func TestGoexitDo(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	go g.Do("key", func() (interface{}, error) {
+		<-release
+		runtime.Goexit()
+		return nil, nil
+	})
+	waitDups(&g, "key", 0)
+
+	res := g.DoChan("key", nil)
+	close(release)
+	select {
+	case r := <-res:
+		if r.Err != errGoexit {
+			t.Fatalf("expect errGoexit, got %v", r.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("waiter deadlocked after fn called runtime.Goexit")
+	}
+}
+
+// 等待 key 对应的请求开始执行，并且有 dups 个等待者
+func waitDups(g *Group, key string, dups int) {
+	for {
+		g.mu.Lock()
+		c, ok := g.m[key]
+		ready := ok && c.dups >= dups
+		g.mu.Unlock()
+		if ready {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+快照，把 mainCache 中的数据保存下来，重启之后恢复，避免冷启动时所有请求都落到数据源上
+*/
+package geecache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 快照格式：
+//
+//	magic "GEEC" | version (1 字节) | 条目个数 (uvarint)
+//	每个条目：key 长度 (uvarint) | key | value 长度 (uvarint) | value | 过期时间 (varint，UnixNano，0 表示永不过期)
+//
+// 条目按访问时间从旧到新排列，按顺序重新添加即可还原访问顺序
+const (
+	snapshotMagic   = "GEEC"
+	snapshotVersion = 1
+	//单个 key 或 value 的长度上限，防止损坏的快照申请过多内存
+	maxSnapshotLen = 1 << 30
+)
+
+// 开启快照文件：NewGroup 时从 path 恢复数据（文件不存在时忽略），
+// SaveSnapshot 把数据写回 path，一般在程序退出前调用
+func WithSnapshotFile(path string) GroupOption {
+	return func(g *Group) {
+		g.snapshotFile = path
+	}
+}
+
+// Snapshot 把 mainCache 中未过期的数据写入 w，包括过期时间，
+// 热点缓存和负缓存不会保存
+func (g *Group) Snapshot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(x uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf, x)])
+	}
+	bw.WriteString(snapshotMagic)
+	bw.WriteByte(snapshotVersion)
+	entries := g.mainCache.entries()
+	writeUvarint(uint64(len(entries)))
+	for _, e := range entries {
+		//快照中保存解压后的值，和压缩配置无关
+		value, err := e.value.decompress()
+		if err != nil {
+			return err
+		}
+		writeUvarint(uint64(len(e.key)))
+		bw.WriteString(e.key)
+		writeUvarint(uint64(value.Len()))
+		bw.Write(value.b)
+		var expire int64
+		if !e.expire.IsZero() {
+			expire = e.expire.UnixNano()
+		}
+		bw.Write(buf[:binary.PutVarint(buf, expire)])
+	}
+	//bufio.Writer 会记住第一个错误，Flush 时返回
+	return bw.Flush()
+}
+
+// Restore 从 r 中读取 Snapshot 写入的数据，添加到 mainCache，
+// 已经过期的条目会被跳过，缓存中已有的同名 key 会被覆盖
+func (g *Group) Restore(r io.Reader) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("read snapshot header: %w", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return errors.New("not a geecache snapshot")
+	}
+	if v := header[len(snapshotMagic)]; v != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", v)
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	now := time.Now()
+	for i := uint64(0); i < n; i++ {
+		key, err := readSnapshotBytes(br)
+		if err != nil {
+			return fmt.Errorf("read snapshot entry %d: %w", i, err)
+		}
+		value, err := readSnapshotBytes(br)
+		if err != nil {
+			return fmt.Errorf("read snapshot entry %d: %w", i, err)
+		}
+		nsec, err := binary.ReadVarint(br)
+		if err != nil {
+			return fmt.Errorf("read snapshot entry %d: %w", i, err)
+		}
+		var expire time.Time
+		if nsec != 0 {
+			expire = time.Unix(0, nsec)
+			if !now.Before(expire) {
+				continue
+			}
+		}
+		g.populateCache(string(key), ByteView{b: value}, expire)
+	}
+	return nil
+}
+
+// 读取一个长度前缀的字节串
+func readSnapshotBytes(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSnapshotLen {
+		return nil, fmt.Errorf("length %d too large", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// SaveSnapshot 把快照写入 WithSnapshotFile 指定的文件，没有指定时什么也不做。
+// 先写临时文件再重命名，写到一半退出也不会破坏原来的快照
+func (g *Group) SaveSnapshot() error {
+	if g.snapshotFile == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(g.snapshotFile), filepath.Base(g.snapshotFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = g.Snapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, g.snapshotFile)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// NewGroup 时从快照文件恢复数据，恢复失败只记录日志，不影响 Group 的使用
+func (g *Group) loadSnapshot() {
+	f, err := os.Open(g.snapshotFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Println("[GeeCache] Failed to open snapshot", err)
+		return
+	}
+	defer f.Close()
+	if err := g.Restore(f); err != nil {
+		log.Println("[GeeCache] Failed to restore snapshot", err)
+	}
+}
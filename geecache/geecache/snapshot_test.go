@@ -0,0 +1,85 @@
+package geecache
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 按 entries 的顺序返回 mainCache 中的 key
+func cacheKeys(c *cache) []string {
+	var keys []string
+	for _, e := range c.entries() {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+func TestSnapshot(t *testing.T) {
+	for _, policy := range []EvictionPolicy{LRU, LFU, ARC, TinyLFU} {
+		getter := GetterFunc(func(key string) ([]byte, error) {
+			return nil, fmt.Errorf("%s not exist", key)
+		})
+		src := NewGroup(fmt.Sprintf("snapshot-%d", policy), 2<<10, getter, WithEvictionPolicy(policy))
+		src.Set("Tom", []byte("630"))
+		src.Set("Jack", []byte("589"))
+		src.populateCache("Sam", ByteView{b: []byte("567")}, time.Now().Add(time.Hour))
+		src.populateCache("Old", ByteView{b: []byte("0")}, time.Now().Add(-time.Second))
+
+		var buf bytes.Buffer
+		if err := src.Snapshot(&buf); err != nil {
+			t.Fatalf("policy %d: Snapshot failed: %v", policy, err)
+		}
+		dst := NewGroup(fmt.Sprintf("restore-%d", policy), 2<<10, getter, WithEvictionPolicy(policy))
+		if err := dst.Restore(&buf); err != nil {
+			t.Fatalf("policy %d: Restore failed: %v", policy, err)
+		}
+		//顺序和过期时间都要保留，过期的条目不会恢复
+		if got, expect := cacheKeys(&dst.mainCache), cacheKeys(&src.mainCache); !reflect.DeepEqual(got, expect) {
+			t.Fatalf("policy %d: restored keys %v, expect %v", policy, got, expect)
+		}
+		for _, e := range dst.mainCache.entries() {
+			if e.key == "Sam" && e.expire.IsZero() {
+				t.Fatalf("policy %d: expire of Sam lost", policy)
+			}
+			if e.key != "Sam" && !e.expire.IsZero() {
+				t.Fatalf("policy %d: %s should never expire", policy, e.key)
+			}
+		}
+		if v, err := dst.Get("Tom"); err != nil || v.String() != "630" {
+			t.Fatalf("policy %d: Get restored Tom = %q, %v", policy, v, err)
+		}
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	g := NewGroup("restore-invalid", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	for _, data := range []string{"", "GEEX\x01\x00", "GEEC\x02\x00", "GEEC\x01\x01\x03ab"} {
+		if err := g.Restore(bytes.NewBufferString(data)); err == nil {
+			t.Fatalf("Restore(%q) should fail", data)
+		}
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.snapshot")
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not exist", key)
+	})
+	//文件不存在时什么也不恢复
+	g := NewGroup("snapshot-file", 2<<10, getter, WithSnapshotFile(path))
+	g.Set("Tom", []byte("630"))
+	if err := g.SaveSnapshot(); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	g = NewGroup("snapshot-file-restart", 2<<10, getter, WithSnapshotFile(path))
+	if v, err := g.Get("Tom"); err != nil || v.String() != "630" {
+		t.Fatalf("Get after restart = %q, %v", v, err)
+	}
+}
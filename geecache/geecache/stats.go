@@ -0,0 +1,73 @@
+package geecache
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// AtomicInt 是可以并发读写的 int64
+type AtomicInt int64
+
+// 原子地加上 n
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// 原子地读取当前值
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
+}
+
+// 编码为 JSON 时也要原子地读取
+func (i *AtomicInt) MarshalJSON() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+// Stats 是一个 Group 的统计数据，所有字段都可以并发读写
+type Stats struct {
+	Gets           AtomicInt `json:"gets"`            // 所有的 Get 请求，包括来自其它节点的
+	CacheHits      AtomicInt `json:"cache_hits"`      // mainCache 或 hotCache 命中
+	PeerLoads      AtomicInt `json:"peer_loads"`      // 从其它节点取回数据
+	PeerErrors     AtomicInt `json:"peer_errors"`     // 从其它节点取数据失败
+	Loads          AtomicInt `json:"loads"`           // 缓存未命中，需要载入的次数
+	LoadsDeduped   AtomicInt `json:"loads_deduped"`   // 经过 singleflight 合并之后真正执行的载入次数
+	LocalLoads     AtomicInt `json:"local_loads"`     // 调用 Getter 成功
+	LocalLoadErrs  AtomicInt `json:"local_load_errs"` // 调用 Getter 失败
+	ServerRequests AtomicInt `json:"server_requests"` // 来自其它节点的 Get 请求
+	NegativeHits   AtomicInt `json:"negative_hits"`   // 负缓存命中，直接返回 ErrNotFound
+}
+
+// CacheStats 是 mainCache 或 hotCache 的统计数据
+type CacheStats struct {
+	Bytes     int64 `json:"bytes"`
+	Items     int64 `json:"items"`
+	Gets      int64 `json:"gets"`
+	Hits      int64 `json:"hits"`
+	Evictions int64 `json:"evictions"` // 内存不足时淘汰的条目数，不包括删除和过期的
+}
+
+// CacheType 表示 Group 中的哪一个缓存
+type CacheType int
+
+const (
+	// 本节点作为拥有者的数据
+	MainCache CacheType = iota + 1
+	// 从其它节点取回的热点数据
+	HotCache
+)
+
+// 返回指定缓存的统计数据
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.stats()
+	case HotCache:
+		return g.hotCache.stats()
+	default:
+		return CacheStats{}
+	}
+}
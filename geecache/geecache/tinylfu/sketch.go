@@ -0,0 +1,127 @@
+package tinylfu
+
+import "hash/fnv"
+
+// 计数器的上限，相当于 4 bit 的计数器
+const maxCount = 15
+
+// count-min sketch 的行数，每一行使用不同的哈希
+const depth = 4
+
+// sketch 用很少的内存近似统计每个 key 的访问频率，
+// 由一个 count-min sketch 和一个 doorkeeper 组成：
+// key 第一次出现只记录在 doorkeeper（布隆过滤器）中，第二次出现才开始计数，
+// 这样大量只出现一次的 key 不会占用计数器。
+// 每记录 resetAt 次就把所有计数减半并清空 doorkeeper，让过去的热点慢慢冷却
+type sketch struct {
+	// depth 行计数器，每行 width 个
+	rows [depth][]uint8
+	// width - 1，width 是 2 的幂
+	mask uint64
+	// doorkeeper 的位图
+	door []uint64
+	// 距离上一次减半记录的次数
+	additions int
+	resetAt   int
+}
+
+// 创建一个 sketch，width 会向上取整为 2 的幂
+func newSketch(width int) *sketch {
+	w := 1
+	for w < width {
+		w <<= 1
+	}
+	s := &sketch{
+		mask:    uint64(w - 1),
+		door:    make([]uint64, (w+63)/64),
+		resetAt: 10 * w,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// 计算 key 的两个哈希，其它的哈希由这两个组合而成
+func hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>32 | h1<<32
+	return h1, h2 | 1
+}
+
+// 第 i 个哈希对应的下标
+func (s *sketch) index(h1, h2 uint64, i int) uint64 {
+	return (h1 + uint64(i)*h2) & s.mask
+}
+
+// doorkeeper 中是否已经记录过，没有则记录下来
+func (s *sketch) allow(h1, h2 uint64) bool {
+	seen := true
+	for i := 0; i < 2; i++ {
+		idx := s.index(h1, h2, depth+i) % uint64(len(s.door)*64)
+		if s.door[idx/64]&(1<<(idx%64)) == 0 {
+			seen = false
+			s.door[idx/64] |= 1 << (idx % 64)
+		}
+	}
+	return seen
+}
+
+// 判断 doorkeeper 中是否记录过，不修改
+func (s *sketch) seen(h1, h2 uint64) bool {
+	for i := 0; i < 2; i++ {
+		idx := s.index(h1, h2, depth+i) % uint64(len(s.door)*64)
+		if s.door[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 记录一次访问
+func (s *sketch) increment(key string) {
+	h1, h2 := hashes(key)
+	if s.allow(h1, h2) {
+		for i := range s.rows {
+			idx := s.index(h1, h2, i)
+			if s.rows[i][idx] < maxCount {
+				s.rows[i][idx]++
+			}
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+// 估计 key 的访问次数，取各行计数的最小值
+func (s *sketch) estimate(key string) int {
+	h1, h2 := hashes(key)
+	least := uint8(maxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h1, h2, i)]; c < least {
+			least = c
+		}
+	}
+	n := int(least)
+	if s.seen(h1, h2) {
+		n++
+	}
+	return n
+}
+
+// 所有计数减半，清空 doorkeeper
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	for i := range s.door {
+		s.door[i] = 0
+	}
+	s.additions = 0
+}
@@ -0,0 +1,184 @@
+package tinylfu
+
+import (
+	"geecache/lru"
+	"time"
+)
+
+// window 占总内存的比例为 1/windowRatio
+const windowRatio = 100
+
+// sketch 计数器个数的上下限
+const (
+	minWidth = 1 << 10
+	maxWidth = 1 << 22
+)
+
+// Cache 是 W-TinyLFU 缓存，接口与 lru.Cache 相同。
+// 新条目先进入一个很小的 window（LRU），从 window 淘汰的条目作为候选者，
+// 和主缓存（LRU）中下一个要淘汰的条目比较 sketch 估计的访问频率，
+// 频率更高才能进入主缓存，否则直接丢弃。
+// 这样只访问一次的大条目不会把主缓存中的热点数据挤出去
+type Cache struct {
+	maxBytes  int64
+	windowMax int64
+	mainMax   int64
+	// 两个 lru 都不限制大小，由这里控制
+	window *lru.Cache
+	main   *lru.Cache
+	sketch *sketch
+	// 正在把条目从 window 移动到主缓存，此时不调用 OnEvicted
+	moving bool
+	// 可选，当条目被清除时执行
+	OnEvicted func(key string, value lru.Value)
+}
+
+func New(maxBytes int64, onEvicted func(string, lru.Value)) *Cache {
+	width := maxBytes / 16
+	if width < minWidth {
+		width = minWidth
+	}
+	if width > maxWidth {
+		width = maxWidth
+	}
+	c := &Cache{
+		maxBytes:  maxBytes,
+		windowMax: maxBytes / windowRatio,
+		sketch:    newSketch(int(width)),
+		OnEvicted: onEvicted,
+	}
+	c.mainMax = maxBytes - c.windowMax
+	c.window = lru.New(0, c.evicted)
+	c.main = lru.New(0, c.evicted)
+	return c
+}
+
+// 内部的 lru 删除条目时调用
+func (c *Cache) evicted(key string, value lru.Value) {
+	if !c.moving && c.OnEvicted != nil {
+		c.OnEvicted(key, value)
+	}
+}
+
+// 查找功能，无论是否命中都记录一次访问
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	c.sketch.increment(key)
+	if value, ok = c.window.Get(key); ok {
+		return
+	}
+	return c.main.Get(key)
+}
+
+// 查找但不记录访问，也不改变访问顺序
+func (c *Cache) Peek(key string) (value lru.Value, expire time.Time, ok bool) {
+	if value, expire, ok = c.window.Peek(key); ok {
+		return
+	}
+	return c.main.Peek(key)
+}
+
+// 添加，永不过期
+func (c *Cache) Add(key string, value lru.Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// 添加，并指定过期时间，expire 为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value lru.Value, expire time.Time) {
+	//已经在主缓存中，直接更新
+	if _, ok := c.main.Get(key); ok {
+		c.main.AddWithExpire(key, value, expire)
+		c.evictMain()
+		return
+	}
+	//新条目总是先进入 window
+	c.window.AddWithExpire(key, value, expire)
+	c.evictWindow()
+}
+
+// window 超过大小时，把最久没有访问的条目作为候选者交给 admit
+func (c *Cache) evictWindow() {
+	for c.maxBytes != 0 && c.window.Bytes() > c.windowMax {
+		ckey, cvalue, cexpire, _ := c.window.Oldest()
+		c.moving = true
+		c.window.Remove(ckey)
+		c.moving = false
+		c.admit(ckey, cvalue, cexpire)
+	}
+}
+
+// 候选者和主缓存中的淘汰者比较访问频率，决定谁留下
+func (c *Cache) admit(key string, value lru.Value, expire time.Time) {
+	size := int64(len(key)) + int64(value.Len())
+	if size > c.mainMax {
+		c.evicted(key, value)
+		return
+	}
+	freq := c.sketch.estimate(key)
+	for c.main.Bytes()+size > c.mainMax {
+		victim, _, _, _ := c.main.Oldest()
+		if freq <= c.sketch.estimate(victim) {
+			//候选者不如淘汰者常用，丢弃候选者
+			c.evicted(key, value)
+			return
+		}
+		c.main.RemoveOldest()
+	}
+	c.main.AddWithExpire(key, value, expire)
+}
+
+// 主缓存中的条目变大之后，按 LRU 淘汰
+func (c *Cache) evictMain() {
+	for c.maxBytes != 0 && c.main.Bytes() > c.mainMax {
+		c.main.RemoveOldest()
+	}
+}
+
+// 淘汰下一个候选者，window 为空时淘汰主缓存中最久没有访问的条目
+func (c *Cache) RemoveOldest() {
+	if c.window.Len() > 0 {
+		c.window.RemoveOldest()
+		return
+	}
+	c.main.RemoveOldest()
+}
+
+// 删除指定的条目，返回条目是否存在
+func (c *Cache) Remove(key string) bool {
+	return c.window.Remove(key) || c.main.Remove(key)
+}
+
+// 删除所有已经过期的条目，返回删除的个数
+func (c *Cache) RemoveExpired() int {
+	return c.window.RemoveExpired() + c.main.RemoveExpired()
+}
+
+// 修改最大内存，window 和主缓存按比例重新分配，变小时立即淘汰，0 表示不限制。
+// sketch 的大小保持不变
+func (c *Cache) SetMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+	c.windowMax = maxBytes / windowRatio
+	c.mainMax = maxBytes - c.windowMax
+	c.evictMain()
+	c.evictWindow()
+}
+
+// 从旧到新遍历所有未过期的条目，先主缓存后 window，fn 返回 false 时停止
+func (c *Cache) Range(fn func(key string, value lru.Value, expire time.Time) bool) {
+	stopped := false
+	c.main.Range(func(key string, value lru.Value, expire time.Time) bool {
+		stopped = !fn(key, value, expire)
+		return !stopped
+	})
+	if !stopped {
+		c.window.Range(fn)
+	}
+}
+
+func (c *Cache) Len() int {
+	return c.window.Len() + c.main.Len()
+}
+
+// 返回当前已使用的内存
+func (c *Cache) Bytes() int64 {
+	return c.window.Bytes() + c.main.Bytes()
+}
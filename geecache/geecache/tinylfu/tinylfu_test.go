@@ -0,0 +1,114 @@
+package tinylfu
+
+import (
+	"geecache/lru"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Add("key1", String("1234"))
+	if v, ok := c.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(16)
+	for i := 0; i < 5; i++ {
+		s.increment("hot")
+	}
+	s.increment("cold")
+	if hot, cold := s.estimate("hot"), s.estimate("cold"); hot <= cold || cold != 1 {
+		t.Fatalf("estimate hot = %d, cold = %d", hot, cold)
+	}
+	s.reset()
+	if hot := s.estimate("hot"); hot != 2 {
+		t.Fatalf("estimate after reset should be halved, got %d", hot)
+	}
+}
+
+// 只访问一次的条目不能把常用的条目挤出去
+func TestAdmission(t *testing.T) {
+	keys := make([]string, 0)
+	c := New(int64(200), func(key string, value lru.Value) {
+		keys = append(keys, key)
+	})
+	for i := 0; i < 10; i++ {
+		c.Get("hot")
+	}
+	c.Add("hot", String("0123456789"))
+	for i := 0; i < 20; i++ {
+		k := "k" + strconv.Itoa(i)
+		c.Get(k)
+		c.Add(k, String("0123456789"))
+	}
+	if _, ok := c.Get("hot"); !ok {
+		t.Fatalf("frequent key hot should not be evicted, evicted %v", keys)
+	}
+	if c.Bytes() > 200 {
+		t.Fatalf("cache uses %d bytes, more than 200", c.Bytes())
+	}
+}
+
+func TestExpire(t *testing.T) {
+	c := New(int64(0), nil)
+	c.AddWithExpire("k1", String("v1"), time.Now().Add(-time.Second))
+	c.Add("k2", String("v2"))
+	if _, ok := c.Get("k1"); ok {
+		t.Fatalf("expired k1 should be a miss")
+	}
+	if c.RemoveExpired() != 0 || !c.Remove("k2") || c.Len() != 0 {
+		t.Fatalf("Remove k2 failed")
+	}
+}
+
+// Zipf 分布的访问序列下，命中率应该高于 lru
+func TestZipfHitRatio(t *testing.T) {
+	const (
+		items    = 10000
+		accesses = 200000
+		capacity = 500
+	)
+	value := String("0123456789")
+	maxBytes := int64(capacity * (len("key9999") + value.Len()))
+	trace := make([]string, accesses)
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), 1.01, 1, items-1)
+	for i := range trace {
+		trace[i] = "key" + strconv.FormatUint(z.Uint64(), 10)
+	}
+
+	type cache interface {
+		Add(key string, value lru.Value)
+		Get(key string) (lru.Value, bool)
+	}
+	hitRatio := func(c cache) float64 {
+		hits := 0
+		for _, k := range trace {
+			if _, ok := c.Get(k); ok {
+				hits++
+			} else {
+				c.Add(k, value)
+			}
+		}
+		return float64(hits) / float64(len(trace))
+	}
+	lruRatio := hitRatio(lru.New(maxBytes, nil))
+	tinyRatio := hitRatio(New(maxBytes, nil))
+	t.Logf("hit ratio: lru %.4f, tinylfu %.4f", lruRatio, tinyRatio)
+	if tinyRatio <= lruRatio {
+		t.Fatalf("tinylfu hit ratio %.4f should be higher than lru %.4f", tinyRatio, lruRatio)
+	}
+}
@@ -2,7 +2,10 @@ module example
 
 go 1.18
 
-require geecache v0.0.0
+require (
+	geecache v0.0.0
+	geerpc v0.0.0
+)
 
 require (
 	github.com/golang/protobuf v1.5.0 // indirect
@@ -12,3 +15,5 @@ require (
 // require google.golang.org/protobuf v1.31.0 // indirect
 
 replace geecache => ./geecache
+
+replace geerpc => ../geerpc
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"geecache"
+	"geerpc/registry"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 var db = map[string]string{
@@ -14,30 +20,60 @@ var db = map[string]string{
 	"Sam":  "567",
 }
 
-func createGroup() *geecache.Group {
+func createGroup(snapshot string) *geecache.Group {
+	opts := []geecache.GroupOption{geecache.WithNegativeTTL(10 * time.Second)}
+	if snapshot != "" {
+		opts = append(opts, geecache.WithSnapshotFile(snapshot))
+	}
 	return geecache.NewGroup("scores", 2<<10, geecache.GetterFunc(
 		func(key string) ([]byte, error) {
 			log.Println("[SlowDB] search key", key)
 			if v, ok := db[key]; ok {
 				return []byte(v), nil
 			}
-			return nil, fmt.Errorf("%s not exist", key)
-		}))
+			return nil, fmt.Errorf("%s not exist: %w", key, geecache.ErrNotFound)
+		}), opts...)
+}
+
+// 收到退出信号时保存快照，下次启动时从快照恢复
+func saveOnShutdown(gee *geecache.Group) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	<-ch
+	if err := gee.SaveSnapshot(); err != nil {
+		log.Println("save snapshot failed:", err)
+	}
+	os.Exit(0)
 }
 
-func startCacheServer(addr string, addrs []string, gee *geecache.Group) {
+func startCacheServer(addr string, addrs []string, registryAddr, adminToken string, gee *geecache.Group) {
 	peers := geecache.NewHTTPPool(addr)
-	peers.Set(addrs...)
+	if registryAddr != "" {
+		//通过注册中心发现其它节点，节点加入或者超时后自动更新
+		peers.Heartbeat(registryAddr, 10*time.Second)
+		peers.Watch(registryAddr, 10*time.Second)
+	} else {
+		peers.Set(addrs...)
+	}
 	gee.RegisterPeers(peers)
+	//运维接口和节点之间的通信使用同一个端口，前缀不同
+	mux := http.NewServeMux()
+	mux.Handle("/_geecache/", peers)
+	mux.Handle("/_geecache_admin/", geecache.NewAdmin(peers, geecache.WithAdminToken(adminToken)))
+	mux.Handle("/metrics", geecache.MetricsHandler())
 	log.Println("geecache is running at", addr)
-	log.Fatal(http.ListenAndServe(addr[7:], peers))
+	log.Fatal(http.ListenAndServe(addr[7:], mux))
 }
 
 func startAPIServer(apiAddr string, gee *geecache.Group) {
 	http.Handle("/api", http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			key := r.URL.Query().Get("key")
-			view, err := gee.Get(key)
+			view, err := gee.GetContext(r.Context(), key)
+			if errors.Is(err, geecache.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -52,9 +88,13 @@ func startAPIServer(apiAddr string, gee *geecache.Group) {
 
 func main() {
 	var port int
-	var api bool
+	var api, discovery bool
+	var snapshot, adminToken string
 	flag.IntVar(&port, "port", 8001, "Geecache server port")
 	flag.BoolVar(&api, "api", false, "Start a api server?")
+	flag.BoolVar(&discovery, "discovery", false, "Discover peers from the registry on the api server?")
+	flag.StringVar(&snapshot, "snapshot", "", "Load the cache from this file on start and save it on shutdown")
+	flag.StringVar(&adminToken, "admin-token", "", "Bearer token required by the admin API, empty means no authentication")
 	//Parse parses the command-line flags from os.Args[1:].
 	//Must be called after all flags are defined and before flags are accessed by the program.
 	flag.Parse()
@@ -71,10 +111,20 @@ func main() {
 		addrs = append(addrs, v)
 	}
 
-	gee := createGroup()
+	//注册中心和api服务运行在同一个端口上
+	var registryAddr string
+	if discovery {
+		registryAddr = apiAddr + "/_geerpc_/registry"
+	}
+
+	gee := createGroup(snapshot)
+	go saveOnShutdown(gee)
 	if api {
+		if discovery {
+			registry.New(30 * time.Second).HandleHTTP("/_geerpc_/registry")
+		}
 		go startAPIServer(apiAddr, gee)
 	}
 	//传入三个参数，分别启动三个节点
-	startCacheServer(addrMap[port], []string(addrs), gee)
+	startCacheServer(addrMap[port], []string(addrs), registryAddr, adminToken, gee)
 }
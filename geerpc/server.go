@@ -1,6 +1,7 @@
 package geerpc
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -78,7 +79,8 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 	defer func() { _ = conn.Close() }()
 	var opt Option
 	//解码Option
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil {
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(&opt); err != nil {
 		log.Println("rpc server: options error: ", err)
 		return
 	}
@@ -93,7 +95,23 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
 		return
 	}
-	server.serveCodec(f(conn), &opt)
+	//json.Decoder 可能已经把 Option 后面的请求一起读进了缓冲区，要把缓冲区中剩下的数据交给编解码器，
+	//客户端的 json.Encoder 会在 Option 后面写一个换行，需要跳过
+	br := bufio.NewReader(io.MultiReader(dec.Buffered(), conn))
+	if b, err := br.Peek(1); err == nil && b[0] == '\n' {
+		_, _ = br.Discard(1)
+	}
+	server.serveCodec(f(&bufferedConn{Reader: br, ReadWriteCloser: conn}), &opt)
+}
+
+//先读取 Reader 中的数据，写入和关闭仍然使用原来的连接
+type bufferedConn struct {
+	io.Reader
+	io.ReadWriteCloser
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
 }
 
 // invalidRequest is a placeholder for response argv when error occurs